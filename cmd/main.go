@@ -1,13 +1,29 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
 	_ "go_cv_test/docs"
+	"go_cv_test/internal/config"
 	"go_cv_test/internal/handlers"
 )
 
 // @BasePath /api/v1
 
 func main() {
-	s := handlers.GetService()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("unable to load settings: %s", err.Error())
+	}
+	s, err := handlers.GetService(cfg)
+	if err != nil {
+		log.Fatalf("unable to start service: %s", err.Error())
+	}
 	s.Run()
 }
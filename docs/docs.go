@@ -1,5 +1,5 @@
-// Package docs Code generated by swaggo/swag. DO NOT EDIT
-package docs
+// Package swagout Code generated by swaggo/swag. DO NOT EDIT
+package swagout
 
 import "github.com/swaggo/swag"
 
@@ -9,12 +9,61 @@ const docTemplate = `{
     "info": {
         "description": "{{escape .Description}}",
         "title": "{{.Title}}",
-        "contact": {},
+        "termsOfService": "http://swagger.io/terms/",
+        "contact": {
+            "name": "API Support",
+            "url": "http://www.swagger.io/support",
+            "email": "support@swagger.io"
+        },
+        "license": {
+            "name": "Apache 2.0",
+            "url": "http://www.apache.org/licenses/LICENSE-2.0.html"
+        },
         "version": "{{.Version}}"
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/config": {
+            "get": {
+                "description": "Return settings after defaults, yaml file, environment variables and flags are applied. Secrets are redacted. Requires Authorization: Bearer \u003cadmin.token\u003e, endpoint isn't registered if token isn't set",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get settings of service",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/config.Config"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/gallery/report": {
+            "get": {
+                "description": "Return photos, which were skipped on loading of current gallery version, with reasons: no_face, face_not_selected, unreadable_image, detector_error, recognizer_error, read_error",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get enrollment report of gallery",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.EnrollmentReport"
+                        }
+                    }
+                }
+            }
+        },
         "/get_status": {
             "post": {
                 "description": "Return current status, 0 - queue, 1 - processing, 2 - error, 3 - canceled, 4 - successful, 5 - paused",
@@ -38,7 +87,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Video"
+                            "$ref": "#/definitions/recognizer.Video"
                         }
                     },
                     "400": {
@@ -50,120 +99,1802 @@ const docTemplate = `{
                 }
             }
         },
-        "/switch_state": {
+        "/health": {
+            "get": {
+                "description": "Return state of shared model pool and of processing slots, 503 is returned if models are not loaded",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get health of the service",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.ServiceHealth"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.ServiceHealth"
+                        }
+                    }
+                }
+            }
+        },
+        "/persons": {
+            "get": {
+                "description": "Return every known person with its photos and descriptors of faces on them",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List persons of gallery",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/recognizer.PersonInfo"
+                            }
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Switch by video ID. This route is used for pausing and unpausing videos from proceeding, paused goroutines wont be deleted",
+                "description": "Add person without photos to gallery, photos are added with /persons/{name}/photos",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Switch state of a video",
+                "summary": "Create person",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "id",
-                        "name": "id",
-                        "in": "query",
-                        "required": true
+                        "description": "name of person",
+                        "name": "person",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.personRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "type": "integer"
+                            "$ref": "#/definitions/recognizer.PersonInfo"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "integer"
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/upload": {
+        "/persons/{name}": {
             "get": {
-                "description": "Return main.html",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get person of gallery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.PersonInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove person with all its photos",
+                "summary": "Delete person",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Running jobs use new name from the next frame, already found matches keep the old name",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get HTML main page",
+                "summary": "Rename person",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "new name of person",
+                        "name": "person",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.personRequest"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "integer"
+                            "$ref": "#/definitions/recognizer.PersonInfo"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "integer"
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/persons/{name}/photos": {
             "post": {
-                "description": "Uploads and immidiately start video processing",
+                "description": "Detect faces on photo, compute descriptor of one of them and save photo in gallery. Face is chosen by box or by index, by default the largest and the most confident face is chosen. Every detected face is returned, also when none of them could be chosen",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Upload video for processing",
+                "summary": "Add photo of person",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "type": "file",
-                        "description": "file",
+                        "description": "photo",
                         "name": "file",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "index of face in returned faces",
+                        "name": "face_index",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "box of face as x0,y0,x1,y1",
+                        "name": "box",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.Enrollment"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/persons/{name}/photos/{photo}": {
+            "delete": {
+                "summary": "Remove photo of person",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "name of photo",
+                        "name": "photo",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/persons/{name}/thresholds": {
+            "put": {
+                "description": "Thresholds of person are set for every metric separately, they override thresholds of jobs with this metric, when person is the closest one. Unset fields are inherited, empty object removes thresholds of person",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Set thresholds of person",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name of person",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "thresholds of person by metric",
+                        "name": "thresholds",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.MetricThresholds"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.PersonInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/switch_state": {
+            "post": {
+                "description": "Switch by video ID. This route is used for pausing and unpausing videos from proceeding, paused goroutines wont be deleted",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Switch state of a video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "integer"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.Video": {
-            "type": "object",
+        },
+        "/upload": {
+            "get": {
+                "description": "Return main.html",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get HTML main page",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Uploads video and puts it in processing queue, returns id of created job without waiting for processing to finish",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Upload video for processing",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "write annotated copy of video",
+                        "name": "annotate",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "live - switch to changed gallery on the next frame, pinned - keep gallery taken on start",
+                        "name": "gallery",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "max distance to the closest person",
+                        "name": "max_distance",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "min difference between distances to the second and to the closest person, for knn - between shares of their votes",
+                        "name": "min_margin",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "max ratio of distances to the closest and to the second person, for knn - of votes of the second and the closest person",
+                        "name": "max_ratio",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "amount of the closest persons kept for every face",
+                        "name": "candidates",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "euclidean or cosine",
+                        "name": "metric",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "analyze every n-th frame",
+                        "name": "stride",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "analyze frames with this rate",
+                        "name": "target_fps",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "analyze one frame of every interval",
+                        "name": "interval_ms",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "histogram - analyze frame after shot boundary, difference - analyze frame after motion",
+                        "name": "gating",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "share of changed pixels or distance between histograms, which starts detection",
+                        "name": "gating_threshold",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "fail_fast - move video to error on failed frame, skip - skip failed frame and continue",
+                        "name": "on_error",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nearest - distance to the closest photo of person, centroid - distance to mean descriptor of person, knn - votes of the closest photos of gallery",
+                        "name": "aggregation",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "integer"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "status resource of created job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos": {
+            "get": {
+                "description": "Return page of videos matching filters. Next page is requested with next_cursor of previous page and the same sorting",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List videos",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "status number or name, can be repeated or comma separated",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "substring of video name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 time, inclusive",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 time, exclusive",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id, name, created_at or updated_at",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 50 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.VideoPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/events": {
+            "get": {
+                "description": "Server-Sent Events stream of status transitions, progress ticks and recognitions of every video",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream events of all videos",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "minimal interval between progress events of one video, like 500ms, 1s by default",
+                        "name": "progress_interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/annotated": {
+            "get": {
+                "description": "Return copy of video with boxes, names and distances of found faces. It is available for videos uploaded with annotate=true after processing is finished",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Download annotated video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/cancel": {
+            "post": {
+                "description": "Stops processing of queued, processing or paused video, video gets status 3 - canceled",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancel video processing",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/detections": {
+            "get": {
+                "description": "Return every face found on frames of video with the closest person, distance to it and runner-up, ordered by frame",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get faces found on video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "name of the closest person",
+                        "name": "person",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "minimal frame timestamp in milliseconds",
+                        "name": "from_ms",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "maximal frame timestamp in milliseconds",
+                        "name": "to_ms",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "minimal confidence of face detector",
+                        "name": "min_confidence",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "return only faces matched to person",
+                        "name": "matched",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/recognizer.FrameDetection"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/events": {
+            "get": {
+                "description": "Server-Sent Events stream of status transitions, progress ticks and recognitions of one video. Current state is sent first, stream is closed after final status",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream events of a video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimal interval between progress events, like 500ms, 1s by default",
+                        "name": "progress_interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/history": {
+            "get": {
+                "description": "Return every status transition of video with its time and reason, from the oldest to the newest",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get status history of a video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/recognizer.StatusChange"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/pause": {
+            "post": {
+                "description": "Worker stops at the next frame and gives its slot to other videos. Only queued or processing videos can be paused",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Pause video processing",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/resume": {
+            "post": {
+                "description": "Paused video is put back in queue and continues from the frame it was paused on",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Resume video processing",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/videos/{id}/timeline": {
+            "get": {
+                "description": "Consecutive matches of the same person are merged into appearance intervals, total screen time of every person is returned as well",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get appearances of persons on video",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "matches separated by not more than this gap are merged, 2000 by default",
+                        "name": "gap_ms",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "shorter appearances are dropped, 0 by default",
+                        "name": "min_duration_ms",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/recognizer.Timeline"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "config.Admin": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "description": "bearer token required by admin endpoints, they aren't registered if it is empty",
+                    "type": "string"
+                }
+            }
+        },
+        "config.Config": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "$ref": "#/definitions/config.Admin"
+                },
+                "recognition": {
+                    "$ref": "#/definitions/config.Recognition"
+                },
+                "server": {
+                    "$ref": "#/definitions/config.Server"
+                },
+                "storage": {
+                    "$ref": "#/definitions/config.Storage"
+                }
+            }
+        },
+        "config.Metric": {
+            "type": "object",
+            "properties": {
+                "calibration_midpoint": {
+                    "description": "distance with confidence 0.5 and distance, within which confidence changes from 0.73 to 0.27",
+                    "type": "number"
+                },
+                "calibration_scale": {
+                    "type": "number"
+                },
+                "max_distance": {
+                    "type": "number"
+                },
+                "max_ratio": {
+                    "type": "number"
+                },
+                "min_margin": {
+                    "type": "number"
+                }
+            }
+        },
+        "config.Recognition": {
+            "type": "object",
+            "properties": {
+                "batch_latency": {
+                    "description": "the longest time, which the first frame of unfinished batch waits for detection",
+                    "type": "string",
+                    "example": "500ms"
+                },
+                "batch_size": {
+                    "description": "amount of frames of one video detected in one call of detector, 1 means detection of every frame separately",
+                    "type": "integer"
+                },
+                "candidates": {
+                    "description": "amount of the closest persons kept for every face",
+                    "type": "integer"
+                },
+                "checkpoint_frames": {
+                    "description": "progress of running video is saved after this amount of frames or this time, whichever comes first. Status\nchanges, pause, cancel and shutdown are saved at once",
+                    "type": "integer"
+                },
+                "checkpoint_interval": {
+                    "type": "string",
+                    "example": "1s"
+                },
+                "cosine": {
+                    "$ref": "#/definitions/config.Metric"
+                },
+                "euclidean": {
+                    "$ref": "#/definitions/config.Metric"
+                },
+                "jittering": {
+                    "description": "amount of generated slightly shifted and rotated copies of face",
+                    "type": "integer"
+                },
+                "knn": {
+                    "description": "margin and ratio of knn aggregation, max_distance of metric is used with it too",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.Votes"
+                        }
+                    ]
+                },
+                "matcher": {
+                    "description": "brute or hnsw",
+                    "type": "string"
+                },
+                "model_sets": {
+                    "description": "amount of loaded sets of models, 0 means amount of workers, but no more than 4",
+                    "type": "integer"
+                },
+                "padding": {
+                    "description": "how much square of detected face is enlarged",
+                    "type": "number"
+                },
+                "workers": {
+                    "description": "amount of videos processed at once, 0 means amount of CPUs",
+                    "type": "integer"
+                }
+            }
+        },
+        "config.Server": {
+            "type": "object",
+            "properties": {
+                "addr": {
+                    "type": "string"
+                },
+                "max_upload_memory_mb": {
+                    "description": "memory limit of multipart form, the rest of upload is kept in temporary files",
+                    "type": "integer"
+                },
+                "shutdown_timeout": {
+                    "description": "time given to running requests to finish on shutdown",
+                    "type": "string",
+                    "example": "5s"
+                },
+                "static_dir": {
+                    "description": "folder with static files of web page and html template of upload page",
+                    "type": "string"
+                },
+                "template": {
+                    "type": "string"
+                },
+                "upload_dir": {
+                    "description": "folder, where uploaded videos are saved",
+                    "type": "string"
+                }
+            }
+        },
+        "config.Storage": {
+            "type": "object",
+            "properties": {
+                "annotated": {
+                    "description": "folder of annotated copies of videos",
+                    "type": "string"
+                },
+                "descriptor_cache": {
+                    "description": "cache of descriptors of photos of persons",
+                    "type": "string"
+                },
+                "gallery_index": {
+                    "description": "saved index of descriptors of persons",
+                    "type": "string"
+                },
+                "jobs": {
+                    "description": "database of jobs",
+                    "type": "string"
+                },
+                "models": {
+                    "description": "folder with dlib models: dlib_face_recognition_resnet_model_v1.dat, mmod_human_face_detector.dat,\nshape_predictor_68_face_landmarks.dat",
+                    "type": "string"
+                },
+                "persons": {
+                    "description": "folder with folder of photos of every person",
+                    "type": "string"
+                }
+            }
+        },
+        "config.Votes": {
+            "type": "object",
+            "properties": {
+                "max_ratio": {
+                    "description": "max ratio of votes of the second and of the closest person",
+                    "type": "number"
+                },
+                "min_margin": {
+                    "description": "min difference between shares of votes of the closest and of the second person",
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.personRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "recognizer.Appearance": {
+            "type": "object",
+            "properties": {
+                "best_distance": {
+                    "description": "the smallest distance to person inside interval",
+                    "type": "number"
+                },
+                "detections": {
+                    "description": "amount of matched detections inside interval",
+                    "type": "integer"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "end_ms": {
+                    "type": "number"
+                },
+                "person": {
+                    "type": "string"
+                },
+                "start": {
+                    "description": "StartMs and EndMs in hh:mm:ss format",
+                    "type": "string"
+                },
+                "start_ms": {
+                    "type": "number"
+                }
+            }
+        },
+        "recognizer.DetectedFace": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "type": "number"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "rectangle": {
+                    "type": "object"
+                },
+                "selected": {
+                    "description": "face was enrolled",
+                    "type": "boolean"
+                }
+            }
+        },
+        "recognizer.Enrollment": {
+            "type": "object",
+            "properties": {
+                "faces": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.DetectedFace"
+                    }
+                },
+                "photo": {
+                    "$ref": "#/definitions/recognizer.PhotoInfo"
+                }
+            }
+        },
+        "recognizer.EnrollmentProblem": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "$ref": "#/definitions/recognizer.ProblemKind"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "person": {
+                    "description": "empty for problems of the whole gallery",
+                    "type": "string"
+                },
+                "photo": {
+                    "description": "empty for problems of folder of person",
+                    "type": "string"
+                }
+            }
+        },
+        "recognizer.EnrollmentReport": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "persons": {
+                    "description": "amount of enrolled persons and photos",
+                    "type": "integer"
+                },
+                "photos": {
+                    "type": "integer"
+                },
+                "problems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.EnrollmentProblem"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "recognizer.Event": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "recognition": {
+                    "$ref": "#/definitions/recognizer.FrameDetection"
+                },
+                "type": {
+                    "$ref": "#/definitions/recognizer.EventType"
+                },
+                "video": {
+                    "$ref": "#/definitions/recognizer.Video"
+                },
+                "video_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "recognizer.EventType": {
+            "type": "string",
+            "enum": [
+                "status",
+                "progress",
+                "recognition"
+            ],
+            "x-enum-varnames": [
+                "StatusEvent",
+                "ProgressEvent",
+                "RecognitionEvent"
+            ]
+        },
+        "recognizer.FrameDetection": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "description": "the closest persons ordered by distance",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.Match"
+                    }
+                },
+                "carried": {
+                    "description": "detection is copied from the last analyzed frame, because frame was gated, see JobOptions.Gating",
+                    "type": "boolean"
+                },
+                "confidence": {
+                    "description": "confidence of face detector",
+                    "type": "number"
+                },
+                "distance": {
+                    "type": "number"
+                },
+                "frame": {
+                    "description": "0-based index of frame",
+                    "type": "integer"
+                },
+                "label": {
+                    "description": "name of matched person or Unknown",
+                    "type": "string"
+                },
+                "match_confidence": {
+                    "description": "calibrated confidence of the closest person",
+                    "type": "number"
+                },
+                "matched": {
+                    "description": "whether the closest person passed every test of thresholds",
+                    "type": "boolean"
+                },
+                "person": {
+                    "description": "the closest person and distance to it",
+                    "type": "string"
+                },
+                "rectangle": {
+                    "type": "object"
+                },
+                "rejection": {
+                    "description": "test, which the closest person failed: distance, margin or ratio",
+                    "type": "string"
+                },
+                "runner_up": {
+                    "description": "the second closest person, it is empty if gallery has only one person",
+                    "type": "string"
+                },
+                "runner_up_distance": {
+                    "type": "number"
+                },
+                "timestamp_ms": {
+                    "description": "position of frame in video",
+                    "type": "number"
+                }
+            }
+        },
+        "recognizer.Gating": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "description": "one of Gating* constants, empty means no gating",
+                    "type": "string"
+                },
+                "threshold": {
+                    "description": "score of frame, which starts detection, 0 means default threshold of mode",
+                    "type": "number"
+                }
+            }
+        },
+        "recognizer.JobError": {
+            "type": "object",
+            "properties": {
+                "frame": {
+                    "description": "index of frame, which was processed, or the frame processing was continued from for stages before frame loop",
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "stage": {
+                    "description": "one of Stage* constants",
+                    "type": "string"
+                }
+            }
+        },
+        "recognizer.JobOptions": {
+            "type": "object",
+            "properties": {
+                "aggregation": {
+                    "description": "one of Aggregate* constants, empty means AggregateNearest",
+                    "type": "string"
+                },
+                "annotate": {
+                    "description": "write copy of video with boxes and names of found persons",
+                    "type": "boolean"
+                },
+                "candidates": {
+                    "description": "amount of the closest persons kept for every face, 0 means global amount",
+                    "type": "integer"
+                },
+                "gallery": {
+                    "description": "one of Gallery* constants, empty means GalleryLive",
+                    "type": "string"
+                },
+                "gating": {
+                    "description": "sampled frames, which are similar to the last analyzed frame, aren't analyzed, zero value disables gating",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/recognizer.Gating"
+                        }
+                    ]
+                },
+                "metric": {
+                    "description": "one of Metric* constants, empty means MetricEuclidean",
+                    "type": "string"
+                },
+                "on_error": {
+                    "description": "one of ErrorPolicy* constants, empty means ErrorPolicyFailFast",
+                    "type": "string"
+                },
+                "sampling": {
+                    "description": "frames, which are analyzed, zero value means every frame",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/recognizer.Sampling"
+                        }
+                    ]
+                },
+                "thresholds": {
+                    "description": "thresholds of recognition of metric of job, which override global ones of this metric",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/recognizer.Thresholds"
+                        }
+                    ]
+                }
+            }
+        },
+        "recognizer.Match": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "description": "distance mapped to [0, 1] by calibration of metric, it isn't set by Matcher",
+                    "type": "number"
+                },
+                "distance": {
+                    "type": "number"
+                },
+                "person": {
+                    "type": "string"
+                },
+                "votes": {
+                    "description": "amount of the closest descriptors of person, it is set only for knn aggregation",
+                    "type": "integer"
+                }
+            }
+        },
+        "recognizer.MetricThresholds": {
+            "type": "object",
+            "additionalProperties": {
+                "$ref": "#/definitions/recognizer.Thresholds"
+            }
+        },
+        "recognizer.PersonInfo": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "photos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.PhotoInfo"
+                    }
+                },
+                "thresholds": {
+                    "description": "thresholds of recognition of this person by metric, they are kept in thresholds.json in folder of person",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/recognizer.MetricThresholds"
+                        }
+                    ]
+                }
+            }
+        },
+        "recognizer.PhotoInfo": {
+            "type": "object",
+            "properties": {
+                "descriptor": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "recognizer.PoolHealth": {
+            "type": "object",
+            "properties": {
+                "acquisitions": {
+                    "type": "integer"
+                },
+                "avg_wait_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "in_use": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "waiting": {
+                    "type": "integer"
+                }
+            }
+        },
+        "recognizer.ProblemKind": {
+            "type": "string",
+            "enum": [
+                "no_face",
+                "face_not_selected",
+                "unreadable_image",
+                "detector_error",
+                "recognizer_error",
+                "read_error",
+                "invalid_thresholds"
+            ],
+            "x-enum-varnames": [
+                "NoFace",
+                "FaceNotSelected",
+                "UnreadableImage",
+                "DetectorError",
+                "RecognizerError",
+                "ReadError",
+                "InvalidThresholds"
+            ]
+        },
+        "recognizer.Sampling": {
+            "type": "object",
+            "properties": {
+                "interval_ms": {
+                    "description": "one frame of every interval of video is analyzed",
+                    "type": "number"
+                },
+                "stride": {
+                    "description": "every Stride-th frame is analyzed",
+                    "type": "integer"
+                },
+                "target_fps": {
+                    "description": "frames are analyzed with this rate, it is limited by fps of video",
+                    "type": "number"
+                }
+            }
+        },
+        "recognizer.ServiceHealth": {
+            "type": "object",
+            "properties": {
+                "busy_slots": {
+                    "type": "integer"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "jobs": {
+                    "description": "amount of videos having running goroutine, including queued and paused ones",
+                    "type": "integer"
+                },
+                "models": {
+                    "$ref": "#/definitions/recognizer.PoolHealth"
+                },
+                "persons": {
+                    "description": "amount of known persons",
+                    "type": "integer"
+                },
+                "slots": {
+                    "description": "max amount of videos processed at the same time and amount of taken slots",
+                    "type": "integer"
+                }
+            }
+        },
+        "recognizer.StatusChange": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "from": {
+                    "$ref": "#/definitions/recognizer.VideoStatus"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "video_status": {
+                    "$ref": "#/definitions/recognizer.VideoStatus"
+                }
+            }
+        },
+        "recognizer.Thresholds": {
+            "type": "object",
+            "properties": {
+                "max_distance": {
+                    "description": "max distance to the closest person",
+                    "type": "number"
+                },
+                "max_ratio": {
+                    "description": "max ratio of distances to the closest and to the second person",
+                    "type": "number"
+                },
+                "min_margin": {
+                    "description": "min difference between distances to the second and to the closest person",
+                    "type": "number"
+                }
+            }
+        },
+        "recognizer.Timeline": {
+            "type": "object",
+            "properties": {
+                "appearances": {
+                    "description": "appearances ordered by start",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.Appearance"
+                    }
+                },
+                "screen_time_ms": {
+                    "description": "sum of durations of appearances of every person",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                }
+            }
+        },
+        "recognizer.Video": {
+            "type": "object",
             "properties": {
+                "annotated": {
+                    "description": "path to annotated copy of video, it is set when annotated output is requested",
+                    "type": "string"
+                },
+                "created_at": {
+                    "description": "timestamps are set by JobStore",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "failure, which moved video to Error",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/recognizer.JobError"
+                        }
+                    ]
+                },
+                "file": {
+                    "description": "path to uploaded file, it is needed for restarting job after restart of the service",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "description": "time of getting final status",
+                    "type": "string"
+                },
+                "fps": {
+                    "description": "frame rate of video, it is known after start of processing",
+                    "type": "number"
+                },
+                "frame": {
+                    "description": "amount of already processed frames, processing is continued from this frame after restart",
+                    "type": "integer"
+                },
+                "gated_frames": {
+                    "description": "amount of sampled frames, which didn't differ enough from the last analyzed frame, see JobOptions.Gating",
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "last_skipped": {
+                    "$ref": "#/definitions/recognizer.JobError"
+                },
                 "name": {
                     "type": "string"
                 },
+                "options": {
+                    "$ref": "#/definitions/recognizer.JobOptions"
+                },
                 "percentage": {
                     "type": "number"
                 },
+                "reason": {
+                    "description": "reason of the last status change",
+                    "type": "string"
+                },
+                "skipped_frames": {
+                    "description": "amount of frames skipped because of failures and the last of them, see JobOptions.OnError",
+                    "type": "integer"
+                },
+                "started_at": {
+                    "description": "time of the first start of processing",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
                 "video_status": {
-                    "$ref": "#/definitions/model.VideoStatus"
+                    "$ref": "#/definitions/recognizer.VideoStatus"
+                }
+            }
+        },
+        "recognizer.VideoPage": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "type": "string"
+                },
+                "videos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recognizer.Video"
+                    }
                 }
             }
         },
-        "model.VideoStatus": {
+        "recognizer.VideoStatus": {
             "type": "integer",
             "enum": [
                 0,
@@ -187,12 +1918,12 @@ const docTemplate = `{
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
-	Version:          "",
-	Host:             "",
+	Version:          "1.0",
+	Host:             "localhost:8080",
 	BasePath:         "/api/v1",
 	Schemes:          []string{},
-	Title:            "",
-	Description:      "",
+	Title:            "Swagger Example API",
+	Description:      "This is a sample server celler server.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -0,0 +1,223 @@
+// Package config describes settings of the service. Settings are read in layers: defaults, then yaml file, then
+// environment variables, then command line flags, every next layer overrides the previous one
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a full set of settings of the service
+type Config struct {
+	Server      Server      `yaml:"server" json:"server"`
+	Storage     Storage     `yaml:"storage" json:"storage"`
+	Recognition Recognition `yaml:"recognition" json:"recognition"`
+	Admin       Admin       `yaml:"admin" json:"admin"`
+}
+
+// Server are settings of http server
+type Server struct {
+	Addr string `yaml:"addr" json:"addr"`
+	//folder with static files of web page and html template of upload page
+	StaticDir string `yaml:"static_dir" json:"static_dir"`
+	Template  string `yaml:"template" json:"template"`
+	//folder, where uploaded videos are saved
+	UploadDir string `yaml:"upload_dir" json:"upload_dir"`
+	//memory limit of multipart form, the rest of upload is kept in temporary files
+	MaxUploadMemoryMB int `yaml:"max_upload_memory_mb" json:"max_upload_memory_mb"`
+	//time given to running requests to finish on shutdown
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" json:"shutdown_timeout" swaggertype:"string" example:"5s"`
+}
+
+// Storage are paths to files and folders of the service
+type Storage struct {
+	//folder with dlib models: dlib_face_recognition_resnet_model_v1.dat, mmod_human_face_detector.dat,
+	//shape_predictor_68_face_landmarks.dat
+	Models string `yaml:"models" json:"models"`
+	//folder with folder of photos of every person
+	Persons string `yaml:"persons" json:"persons"`
+	//database of jobs
+	Jobs string `yaml:"jobs" json:"jobs"`
+	//cache of descriptors of photos of persons
+	DescriptorCache string `yaml:"descriptor_cache" json:"descriptor_cache"`
+	//saved index of descriptors of persons
+	GalleryIndex string `yaml:"gallery_index" json:"gallery_index"`
+	//folder of annotated copies of videos
+	Annotated string `yaml:"annotated" json:"annotated"`
+}
+
+// Recognition are settings of processing of videos
+type Recognition struct {
+	//amount of videos processed at once, 0 means amount of CPUs
+	Workers int `yaml:"workers" json:"workers"`
+	//amount of loaded sets of models, 0 means amount of workers, but no more than 4
+	ModelSets int `yaml:"model_sets" json:"model_sets"`
+	//how much square of detected face is enlarged
+	Padding float64 `yaml:"padding" json:"padding"`
+	//amount of generated slightly shifted and rotated copies of face
+	Jittering int `yaml:"jittering" json:"jittering"`
+	//amount of frames of one video detected in one call of detector, 1 means detection of every frame separately
+	BatchSize int `yaml:"batch_size" json:"batch_size"`
+	//the longest time, which the first frame of unfinished batch waits for detection
+	BatchLatency Duration `yaml:"batch_latency" json:"batch_latency" swaggertype:"string" example:"500ms"`
+	//progress of running video is saved after this amount of frames or this time, whichever comes first. Status
+	//changes, pause, cancel and shutdown are saved at once
+	CheckpointFrames   int      `yaml:"checkpoint_frames" json:"checkpoint_frames"`
+	CheckpointInterval Duration `yaml:"checkpoint_interval" json:"checkpoint_interval" swaggertype:"string" example:"1s"`
+	//brute or hnsw
+	Matcher string `yaml:"matcher" json:"matcher"`
+	//amount of the closest persons kept for every face
+	Candidates int    `yaml:"candidates" json:"candidates"`
+	Euclidean  Metric `yaml:"euclidean" json:"euclidean"`
+	Cosine     Metric `yaml:"cosine" json:"cosine"`
+	//margin and ratio of knn aggregation, max_distance of metric is used with it too
+	KNN Votes `yaml:"knn" json:"knn"`
+}
+
+// Metric are global thresholds and calibration of confidence of one metric of distance
+type Metric struct {
+	MaxDistance float64 `yaml:"max_distance" json:"max_distance"`
+	MinMargin   float64 `yaml:"min_margin" json:"min_margin"`
+	MaxRatio    float64 `yaml:"max_ratio" json:"max_ratio"`
+	//distance with confidence 0.5 and distance, within which confidence changes from 0.73 to 0.27
+	CalibrationMidpoint float64 `yaml:"calibration_midpoint" json:"calibration_midpoint"`
+	CalibrationScale    float64 `yaml:"calibration_scale" json:"calibration_scale"`
+}
+
+// Votes are thresholds of knn aggregation, they are computed from shares of votes of the closest descriptors instead
+// of distances
+type Votes struct {
+	//min difference between shares of votes of the closest and of the second person
+	MinMargin float64 `yaml:"min_margin" json:"min_margin"`
+	//max ratio of votes of the second and of the closest person
+	MaxRatio float64 `yaml:"max_ratio" json:"max_ratio"`
+}
+
+// Admin are settings of admin endpoints
+type Admin struct {
+	//bearer token required by admin endpoints, they aren't registered if it is empty
+	Token string `yaml:"token" json:"token" secret:"true"`
+}
+
+// Default returns settings used when they aren't overridden
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			StaticDir:         "../web/static",
+			Template:          "../web/static/templates/main.html",
+			UploadDir:         "./files",
+			MaxUploadMemoryMB: 8,
+			ShutdownTimeout:   Duration(5 * time.Second)},
+		Storage: Storage{
+			Models:          "./internal/recognizer/app/models",
+			Persons:         "./internal/recognizer/app/persons",
+			Jobs:            "./data/jobs.db",
+			DescriptorCache: "./data/descriptors.cache",
+			GalleryIndex:    "./data/gallery.index",
+			Annotated:       "./files/annotated"},
+		Recognition: Recognition{
+			Padding:   0.2,
+			Jittering: 30,
+			//frames of batch are kept in memory until detection
+			BatchSize:    4,
+			BatchLatency: Duration(500 * time.Millisecond),
+			//every save is a synchronous write of database
+			CheckpointFrames:   100,
+			CheckpointInterval: Duration(time.Second),
+			Matcher:            "brute",
+			Candidates:         3,
+			//distances of photos of the same person are usually below 0.5
+			Euclidean: Metric{MaxDistance: 0.5, MinMargin: 0.05, MaxRatio: 0.9, CalibrationMidpoint: 0.5, CalibrationScale: 0.05},
+			//cosine distance of normalized descriptors is about half of square of euclidean one
+			Cosine: Metric{MaxDistance: 0.125, MinMargin: 0.025, MaxRatio: 0.9, CalibrationMidpoint: 0.125, CalibrationScale: 0.025},
+			//the closest person needs at least one vote of 5 more than the second one
+			KNN: Votes{MinMargin: 0.2, MaxRatio: 0.75}}}
+}
+
+// Validate returns every invalid setting
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Server.Addr != "", "server.addr is empty")
+	check(c.Server.UploadDir != "", "server.upload_dir is empty")
+	check(c.Server.MaxUploadMemoryMB > 0, "server.max_upload_memory_mb should be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout should be positive")
+	for key, value := range map[string]string{
+		"storage.models":           c.Storage.Models,
+		"storage.persons":          c.Storage.Persons,
+		"storage.jobs":             c.Storage.Jobs,
+		"storage.descriptor_cache": c.Storage.DescriptorCache,
+		"storage.gallery_index":    c.Storage.GalleryIndex,
+		"storage.annotated":        c.Storage.Annotated,
+	} {
+		check(value != "", "%s is empty", key)
+	}
+	r := c.Recognition
+	check(r.Workers >= 0, "recognition.workers shouldn't be negative")
+	check(r.ModelSets >= 0, "recognition.model_sets shouldn't be negative")
+	check(r.Padding >= 0, "recognition.padding shouldn't be negative")
+	check(r.Jittering >= 0, "recognition.jittering shouldn't be negative")
+	check(r.BatchSize > 0, "recognition.batch_size should be positive")
+	check(r.BatchLatency > 0, "recognition.batch_latency should be positive")
+	check(r.CheckpointFrames > 0, "recognition.checkpoint_frames should be positive")
+	check(r.CheckpointInterval > 0, "recognition.checkpoint_interval should be positive")
+	check(r.Matcher == "brute" || r.Matcher == "hnsw", "recognition.matcher should be brute or hnsw")
+	check(r.Candidates > 0, "recognition.candidates should be positive")
+	for name, m := range map[string]Metric{"euclidean": r.Euclidean, "cosine": r.Cosine} {
+		check(m.MaxDistance > 0, "recognition.%s.max_distance should be positive", name)
+		check(m.MinMargin >= 0, "recognition.%s.min_margin shouldn't be negative", name)
+		check(m.MaxRatio > 0 && m.MaxRatio <= 1, "recognition.%s.max_ratio should be in (0, 1]", name)
+		check(m.CalibrationScale > 0, "recognition.%s.calibration_scale should be positive", name)
+	}
+	check(r.KNN.MinMargin >= 0 && r.KNN.MinMargin <= 1, "recognition.knn.min_margin should be in [0, 1]")
+	check(r.KNN.MaxRatio > 0 && r.KNN.MaxRatio <= 1, "recognition.knn.max_ratio should be in (0, 1]")
+	return errors.Join(errs...)
+}
+
+// Мета-значение, которым заменяются секреты в Redacted.
+const redacted = "[redacted]"
+
+// Redacted returns copy of settings, where non-empty fields tagged with secret:"true" are replaced
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	walk(v, "", func(_ string, field reflect.Value, tag reflect.StructTag) {
+		if tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString(redacted)
+		}
+	})
+	return c
+}
+
+// Duration is time.Duration written as "5s" in yaml, json, environment variables and flags
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
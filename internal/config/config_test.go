@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writes yaml file of settings into temporary folder
+func writeFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// every layer overrides the previous one: defaults, yaml file, GCV_ variables, flags
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		want int
+	}{
+		{name: "defaults", want: 3},
+		{name: "yaml", yaml: "recognition:\n  candidates: 5\n", want: 5},
+		{name: "env over default", env: map[string]string{"GCV_RECOGNITION_CANDIDATES": "7"}, want: 7},
+		{name: "env over yaml", yaml: "recognition:\n  candidates: 5\n",
+			env: map[string]string{"GCV_RECOGNITION_CANDIDATES": "7"}, want: 7},
+		{name: "flag over yaml", yaml: "recognition:\n  candidates: 5\n", args: []string{"-recognition.candidates=9"}, want: 9},
+		{name: "flag over env", yaml: "recognition:\n  candidates: 5\n",
+			env: map[string]string{"GCV_RECOGNITION_CANDIDATES": "7"}, args: []string{"-recognition.candidates", "9"}, want: 9},
+		{name: "other keys are kept", yaml: "recognition:\n  matcher: hnsw\n",
+			env: map[string]string{"GCV_RECOGNITION_JITTERING": "2"}, want: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.yaml != "" {
+				args = append([]string{"-config", writeFile(t, test.yaml)}, args...)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			c, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Recognition.Candidates != test.want {
+				t.Errorf("recognition.candidates = %d, want %d", c.Recognition.Candidates, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := writeFile(t, "server:\n  shutdown_timeout: 10s\nrecognition:\n  matcher: hnsw\n  cosine:\n    max_ratio: 0.8\n")
+	//file is also found by variable
+	t.Setenv("GCV_CONFIG", file)
+	t.Setenv("GCV_ADMIN_TOKEN", "secret")
+	t.Setenv("GCV_RECOGNITION_BATCH_LATENCY", "1s")
+	c, err := Load([]string{"-server.addr", ":9090"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Server.ShutdownTimeout = Duration(10 * time.Second)
+	want.Server.Addr = ":9090"
+	want.Recognition.Matcher = "hnsw"
+	want.Recognition.Cosine.MaxRatio = 0.8
+	want.Recognition.BatchLatency = Duration(time.Second)
+	want.Admin.Token = "secret"
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Load returned %+v, want %+v", c, want)
+	}
+}
+
+// example file documents defaults, so it shouldn't change them
+func TestExampleIsDefault(t *testing.T) {
+	c, err := Load([]string{"-config", "../../config.example.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("config.example.yaml differs from defaults: %+v", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		//part of error message
+		want string
+	}{
+		{name: "unknown key", yaml: "recognition:\n  candidats: 5\n", want: "candidats"},
+		{name: "unknown section", yaml: "storge:\n  jobs: jobs.db\n", want: "storge"},
+		{name: "wrong type", yaml: "recognition:\n  candidates: many\n", want: "many"},
+		{name: "wrong duration", yaml: "server:\n  shutdown_timeout: 5\n", want: "line 2"},
+		{name: "missing file", args: []string{"-config", "missing.yaml"}, want: "missing.yaml"},
+		{name: "wrong env", env: map[string]string{"GCV_RECOGNITION_WORKERS": "two"}, want: "GCV_RECOGNITION_WORKERS"},
+		{name: "wrong flag", args: []string{"-recognition.padding", "wide"}, want: "recognition.padding"},
+		{name: "unknown flag", args: []string{"-recognition.unknown", "1"}, want: "recognition.unknown"},
+		{name: "invalid value", env: map[string]string{"GCV_RECOGNITION_MATCHER": "exact"}, want: "invalid settings"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.yaml != "" {
+				args = append([]string{"-config", writeFile(t, test.yaml)}, args...)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Load returned %v, want error with %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		//every message is expected in error
+		want []string
+	}{
+		{"default", func(c *Config) {}, nil},
+		{"empty addr", func(c *Config) { c.Server.Addr = "" }, []string{"server.addr is empty"}},
+		{"empty storage", func(c *Config) { c.Storage.Jobs = "" }, []string{"storage.jobs is empty"}},
+		{"negative workers", func(c *Config) { c.Recognition.Workers = -1 }, []string{"recognition.workers"}},
+		{"zero batch", func(c *Config) { c.Recognition.BatchSize = 0 }, []string{"recognition.batch_size"}},
+		{"zero checkpoint", func(c *Config) { c.Recognition.CheckpointInterval = 0 }, []string{"recognition.checkpoint_interval"}},
+		{"unknown matcher", func(c *Config) { c.Recognition.Matcher = "exact" }, []string{"recognition.matcher"}},
+		{"ratio above 1", func(c *Config) { c.Recognition.Cosine.MaxRatio = 1.5 }, []string{"recognition.cosine.max_ratio"}},
+		{"knn margin above 1", func(c *Config) { c.Recognition.KNN.MinMargin = 1.2 }, []string{"recognition.knn.min_margin"}},
+		{"zero knn ratio", func(c *Config) { c.Recognition.KNN.MaxRatio = 0 }, []string{"recognition.knn.max_ratio"}},
+		{"every error is returned", func(c *Config) {
+			c.Server.MaxUploadMemoryMB = 0
+			c.Recognition.Candidates = 0
+			c.Recognition.Euclidean.MinMargin = -1
+		}, []string{"server.max_upload_memory_mb", "recognition.candidates", "recognition.euclidean.min_margin"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := Default()
+			test.change(&c)
+			err := c.Validate()
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Errorf("valid settings were rejected: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("invalid settings were accepted")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"secret", redacted},
+		//empty secret shows that it isn't set
+		{"", ""},
+	}
+	for _, test := range tests {
+		c := Default()
+		c.Admin.Token = test.token
+		r := c.Redacted()
+		if r.Admin.Token != test.want {
+			t.Errorf("token %q was redacted to %q, want %q", test.token, r.Admin.Token, test.want)
+		}
+		if c.Admin.Token != test.token {
+			t.Errorf("Redacted changed original settings")
+		}
+		//fields without secret tag are kept
+		r.Admin.Token = c.Admin.Token
+		if !reflect.DeepEqual(r, c) {
+			t.Errorf("Redacted changed other settings: %+v", r)
+		}
+	}
+}
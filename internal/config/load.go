@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Префикс переменных окружения: настройка recognition.candidates читается из GCV_RECOGNITION_CANDIDATES.
+const envPrefix = "GCV_"
+
+// Load reads settings from yaml file given by -config flag or GCV_CONFIG variable, then from environment variables
+// and from flags in args. Every setting has flag with its yaml path, for example -server.addr. Settings are validated
+func Load(args []string) (Config, error) {
+	c := Default()
+
+	//flags are parsed first to find config file, but they are applied last
+	fs := flag.NewFlagSet("go_cv_test", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to yaml file with settings")
+	values := make(map[string]string)
+	walk(reflect.ValueOf(&c).Elem(), "", func(key string, field reflect.Value, tag reflect.StructTag) {
+		fs.Var(&flagValue{key: key, values: values, def: fmt.Sprint(field.Interface())}, key, "overrides "+key)
+	})
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *file != "" {
+		if err := c.readFile(*file); err != nil {
+			return c, err
+		}
+	}
+
+	var errs []error
+	walk(reflect.ValueOf(&c).Elem(), "", func(key string, field reflect.Value, _ reflect.StructTag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if s, ok := os.LookupEnv(name); ok {
+			if err := set(field, s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		if s, ok := values[key]; ok {
+			if err := set(field, s); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", key, err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return c, err
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("invalid settings: %w", err)
+	}
+	return c, nil
+}
+
+// reads yaml file over current settings, unknown keys are treated as errors, so misprints aren't ignored
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// walk calls visit for every field of settings, which isn't a struct, with its path of yaml keys joined by dots
+func walk(v reflect.Value, prefix string, visit func(key string, field reflect.Value, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if field := v.Field(i); field.Kind() == reflect.Struct {
+			walk(field, key, visit)
+		} else {
+			visit(key, field, t.Field(i).Tag)
+		}
+	}
+}
+
+// parses s into field of settings
+func set(field reflect.Value, s string) error {
+	if field.Type() == reflect.TypeOf(Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// flagValue keeps value of flag until file and environment are applied
+type flagValue struct {
+	key    string
+	values map[string]string
+	//default shown in usage
+	def string
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *flagValue) Set(s string) error {
+	f.values[f.key] = s
+	return nil
+}
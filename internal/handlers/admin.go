@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checks bearer token of admin endpoints. Endpoints aren't registered without token, but empty token rejects every
+// request anyway, so they are never open
+func (service *VideoService) requireAdmin(c *gin.Context) {
+	token := service.config.Admin.Token
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "admin token is required")
+	}
+}
+
+// GetConfig godoc
+//
+//	@Summary		Get settings of service
+//	@Description	Return settings after defaults, yaml file, environment variables and flags are applied. Secrets are redacted. Requires Authorization: Bearer <admin.token>, endpoint isn't registered if token isn't set
+//	@Produce		json
+//	@Success		200	{object}	config.Config
+//	@Failure		401	{object}	string
+//	@Router			/admin/config [get]
+func (service *VideoService) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, service.config.Redacted())
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDetections godoc
+//
+//	@Summary		Get faces found on video
+//	@Description	Return every face found on frames of video with the closest person, distance to it and runner-up, ordered by frame
+//	@Produce		json
+//	@Param			id				path		int		true	"id"
+//	@Param			person			query		string	false	"name of the closest person"
+//	@Param			from_ms			query		number	false	"minimal frame timestamp in milliseconds"
+//	@Param			to_ms			query		number	false	"maximal frame timestamp in milliseconds"
+//	@Param			min_confidence	query		number	false	"minimal confidence of face detector"
+//	@Param			matched			query		bool	false	"return only faces matched to person"
+//	@Success		200				{array}		model.FrameDetection
+//	@Failure		400				{object}	string
+//	@Failure		404				{object}	string
+//	@Router			/videos/{id}/detections [get]
+func (service *VideoService) GetDetections(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	filter := model.DetectionFilter{Person: c.Query("person")}
+	for param, value := range map[string]*float64{
+		"from_ms":        &filter.FromMs,
+		"to_ms":          &filter.ToMs,
+		"min_confidence": &filter.MinConfidence,
+	} {
+		if s := c.Query(param); s != "" {
+			var err error
+			if *value, err = strconv.ParseFloat(s, 64); err != nil {
+				c.String(http.StatusBadRequest, "%s should be a number", param)
+				return
+			}
+		}
+	}
+	if s := c.Query("matched"); s != "" {
+		var err error
+		if filter.MatchedOnly, err = strconv.ParseBool(s); err != nil {
+			c.String(http.StatusBadRequest, "matched should be true or false")
+			return
+		}
+	}
+	detections, err := service.vP.GetDetections(id, filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if detections == nil {
+		detections = []model.FrameDetection{}
+	}
+	c.JSON(http.StatusOK, detections)
+}
+
+// GetAnnotated godoc
+//
+//	@Summary		Download annotated video
+//	@Description	Return copy of video with boxes, names and distances of found faces. It is available for videos uploaded with annotate=true after processing is finished
+//	@Produce		octet-stream
+//	@Param			id	path		int	true	"id"
+//	@Success		200	{file}		file
+//	@Failure		400	{object}	string
+//	@Failure		404	{object}	string
+//	@Failure		409	{object}	string
+//	@Router			/videos/{id}/annotated [get]
+func (service *VideoService) GetAnnotated(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	path, err := service.vP.AnnotatedFile(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.FileAttachment(path, "annotated_"+filepath.Base(path))
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// interval of comments sent to idle stream, so proxies don't close it
+const keepAliveInterval = 15 * time.Second
+
+// VideoEvents godoc
+//
+//	@Summary		Stream events of a video
+//	@Description	Server-Sent Events stream of status transitions, progress ticks and recognitions of one video. Current state is sent first, stream is closed after final status
+//	@Produce		text/event-stream
+//	@Param			id					path		int		true	"id"
+//	@Param			progress_interval	query		string	false	"minimal interval between progress events, like 500ms, 1s by default"
+//	@Success		200					{object}	model.Event
+//	@Failure		400					{object}	string
+//	@Failure		404					{object}	string
+//	@Router			/videos/{id}/events [get]
+func (service *VideoService) VideoEvents(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	service.streamEvents(c, id)
+}
+
+// AllEvents godoc
+//
+//	@Summary		Stream events of all videos
+//	@Description	Server-Sent Events stream of status transitions, progress ticks and recognitions of every video
+//	@Produce		text/event-stream
+//	@Param			progress_interval	query		string	false	"minimal interval between progress events of one video, like 500ms, 1s by default"
+//	@Success		200					{object}	model.Event
+//	@Failure		400					{object}	string
+//	@Router			/videos/events [get]
+func (service *VideoService) AllEvents(c *gin.Context) {
+	service.streamEvents(c, 0)
+}
+
+// streams events of video with given id, or of every video if id is 0
+func (service *VideoService) streamEvents(c *gin.Context, id int32) {
+	interval := model.DefaultProgressInterval
+	if s := c.Query("progress_interval"); s != "" {
+		var err error
+		if interval, err = time.ParseDuration(s); err != nil || interval < 0 {
+			c.String(http.StatusBadRequest, "progress_interval should be duration like 500ms")
+			return
+		}
+	}
+	events, err := service.vP.Subscribe(c.Request.Context(), id, interval)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("X-Accel-Buffering", "no")
+	if id != 0 {
+		video, err := service.vP.GetVideo(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.SSEvent(string(model.StatusEvent), model.Event{Type: model.StatusEvent, VideoId: id, At: video.UpdatedAt, Video: &video})
+		c.Writer.Flush()
+		if video.Status.IsFinal() {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(string(event.Type), event)
+			// stream of one video is finished together with the video
+			return id == 0 || event.Type != model.StatusEvent || !event.Video.Status.IsFinal()
+		case <-keepAlive.C:
+			// status in store is checked too, so stream is finished even if final event was missed
+			if id != 0 {
+				if video, err := service.vP.GetVideo(id); err == nil && video.Status.IsFinal() {
+					c.SSEvent(string(model.StatusEvent), model.Event{Type: model.StatusEvent, VideoId: id, At: video.UpdatedAt, Video: &video})
+					return false
+				}
+			}
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHealth godoc
+//
+//	@Summary		Get health of the service
+//	@Description	Return state of shared model pool and of processing slots, 503 is returned if models are not loaded
+//	@Produce		json
+//	@Success		200	{object}	recognizer.ServiceHealth
+//	@Failure		503	{object}	recognizer.ServiceHealth
+//	@Router			/health [get]
+func (service *VideoService) GetHealth(c *gin.Context) {
+	health := service.vP.Health()
+	if !health.Healthy {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parses id of video from path of request, writes 400 if id is not a number
+func videoId(c *gin.Context) (int32, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Unable to process id")
+		return 0, false
+	}
+	return int32(id), true
+}
+
+// writes error returned by VideoProcessor with matching http status
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrVideoNotFound), errors.Is(err, model.ErrNotAnnotated),
+		errors.Is(err, model.ErrPersonNotFound), errors.Is(err, model.ErrPhotoNotFound):
+		c.JSON(http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrIllegalTransition), errors.Is(err, model.ErrNotReady),
+		errors.Is(err, model.ErrPersonExists), errors.Is(err, model.ErrPhotoExists):
+		c.JSON(http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrInvalidPerson), errors.Is(err, model.ErrInvalidThresholds):
+		c.JSON(http.StatusBadRequest, err.Error())
+	case errors.Is(err, model.ErrUnreadableImage), errors.Is(err, model.ErrNoFace), errors.Is(err, model.ErrFaceNotSelected):
+		c.JSON(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, model.ErrModelsNotLoaded):
+		c.JSON(http.StatusServiceUnavailable, err.Error())
+	default:
+		c.JSON(http.StatusInternalServerError, err.Error())
+	}
+}
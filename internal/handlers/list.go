@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListVideos godoc
+//
+//	@Summary		List videos
+//	@Description	Return page of videos matching filters. Next page is requested with next_cursor of previous page and the same sorting
+//	@Produce		json
+//	@Param			status			query		[]string	false	"status number or name, can be repeated or comma separated"
+//	@Param			name			query		string		false	"substring of video name"
+//	@Param			created_from	query		string		false	"RFC3339 time, inclusive"
+//	@Param			created_to		query		string		false	"RFC3339 time, exclusive"
+//	@Param			sort			query		string		false	"id, name, created_at or updated_at"
+//	@Param			order			query		string		false	"asc or desc"
+//	@Param			limit			query		int			false	"page size, 50 by default"
+//	@Param			cursor			query		string		false	"next_cursor of previous page"
+//	@Success		200				{object}	model.VideoPage
+//	@Failure		400				{object}	string
+//	@Router			/videos [get]
+func (service *VideoService) ListVideos(c *gin.Context) {
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := service.vP.ListVideos(query)
+	if errors.Is(err, model.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func parseListQuery(c *gin.Context) (model.ListQuery, error) {
+	query := model.ListQuery{
+		Name:   c.Query("name"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+	for _, param := range c.QueryArray("status") {
+		for _, s := range strings.Split(param, ",") {
+			status, err := model.ParseVideoStatus(strings.TrimSpace(s))
+			if err != nil {
+				return query, err
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+	var err error
+	if s := c.Query("created_from"); s != "" {
+		if query.CreatedFrom, err = time.Parse(time.RFC3339, s); err != nil {
+			return query, errors.New("created_from should be RFC3339 time")
+		}
+	}
+	if s := c.Query("created_to"); s != "" {
+		if query.CreatedTo, err = time.Parse(time.RFC3339, s); err != nil {
+			return query, errors.New("created_to should be RFC3339 time")
+		}
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errors.New("order should be asc or desc")
+	}
+	if s := c.Query("limit"); s != "" {
+		if query.Limit, err = strconv.Atoi(s); err != nil || query.Limit < 1 {
+			return query, errors.New("limit should be positive number")
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// body of create and rename requests
+type personRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ListPersons godoc
+//
+//	@Summary		List persons of gallery
+//	@Description	Return every known person with its photos and descriptors of faces on them
+//	@Produce		json
+//	@Success		200	{array}	model.PersonInfo
+//	@Router			/persons [get]
+func (service *VideoService) ListPersons(c *gin.Context) {
+	c.JSON(http.StatusOK, service.vP.ListPersons())
+}
+
+// GetPerson godoc
+//
+//	@Summary		Get person of gallery
+//	@Produce		json
+//	@Param			name	path		string	true	"name of person"
+//	@Success		200		{object}	model.PersonInfo
+//	@Failure		404		{object}	string
+//	@Router			/persons/{name} [get]
+func (service *VideoService) GetPerson(c *gin.Context) {
+	person, err := service.vP.GetPerson(c.Param("name"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, person)
+}
+
+// CreatePerson godoc
+//
+//	@Summary		Create person
+//	@Description	Add person without photos to gallery, photos are added with /persons/{name}/photos
+//	@Accept			json
+//	@Produce		json
+//	@Param			person	body		personRequest	true	"name of person"
+//	@Success		201		{object}	model.PersonInfo
+//	@Failure		400		{object}	string
+//	@Failure		409		{object}	string
+//	@Router			/persons [post]
+func (service *VideoService) CreatePerson(c *gin.Context) {
+	var request personRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	person, err := service.vP.CreatePerson(request.Name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, person)
+}
+
+// RenamePerson godoc
+//
+//	@Summary		Rename person
+//	@Description	Running jobs use new name from the next frame, already found matches keep the old name
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string			true	"name of person"
+//	@Param			person	body		personRequest	true	"new name of person"
+//	@Success		200		{object}	model.PersonInfo
+//	@Failure		400		{object}	string
+//	@Failure		404		{object}	string
+//	@Failure		409		{object}	string
+//	@Router			/persons/{name} [patch]
+func (service *VideoService) RenamePerson(c *gin.Context) {
+	var request personRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	person, err := service.vP.RenamePerson(c.Param("name"), request.Name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, person)
+}
+
+// DeletePerson godoc
+//
+//	@Summary		Delete person
+//	@Description	Remove person with all its photos
+//	@Param			name	path	string	true	"name of person"
+//	@Success		204
+//	@Failure		404	{object}	string
+//	@Router			/persons/{name} [delete]
+func (service *VideoService) DeletePerson(c *gin.Context) {
+	if err := service.vP.DeletePerson(c.Param("name")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetPersonThresholds godoc
+//
+//	@Summary		Set thresholds of person
+//	@Description	Thresholds of person are set for every metric separately, they override thresholds of jobs with this metric, when person is the closest one. Unset fields are inherited, empty object removes thresholds of person
+//	@Accept			json
+//	@Produce		json
+//	@Param			name		path		string				true	"name of person"
+//	@Param			thresholds	body		model.MetricThresholds	true	"thresholds of person by metric"
+//	@Success		200			{object}	model.PersonInfo
+//	@Failure		400			{object}	string
+//	@Failure		404			{object}	string
+//	@Router			/persons/{name}/thresholds [put]
+func (service *VideoService) SetPersonThresholds(c *gin.Context) {
+	var thresholds model.MetricThresholds
+	if err := c.ShouldBindJSON(&thresholds); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	person, err := service.vP.SetPersonThresholds(c.Param("name"), thresholds)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, person)
+}
+
+// AddPhoto godoc
+//
+//	@Summary		Add photo of person
+//	@Description	Detect faces on photo, compute descriptor of one of them and save photo in gallery. Face is chosen by box or by index, by default the largest and the most confident face is chosen. Every detected face is returned, also when none of them could be chosen
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			name		path		string	true	"name of person"
+//	@Param			file		formData	file	true	"photo"
+//	@Param			face_index	formData	int		false	"index of face in returned faces"
+//	@Param			box			formData	string	false	"box of face as x0,y0,x1,y1"
+//	@Success		201		{object}	model.Enrollment
+//	@Failure		400		{object}	string
+//	@Failure		404		{object}	string
+//	@Failure		409		{object}	string
+//	@Failure		422		{object}	string
+//	@Failure		503		{object}	string
+//	@Router			/persons/{name}/photos [post]
+func (service *VideoService) AddPhoto(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(http.StatusBadRequest, "unable to get file: %s", err.Error())
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.String(http.StatusBadRequest, "unable to open file: %s", err.Error())
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		c.String(http.StatusBadRequest, "unable to read file: %s", err.Error())
+		return
+	}
+	selector, err := parseFaceSelector(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	enrollment, err := service.vP.AddPhoto(c.Request.Context(), c.Param("name"), file.Filename, data, selector)
+	if errors.Is(err, model.ErrFaceNotSelected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "faces": enrollment.Faces})
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+// reads face_index and box of enrolled face from form of request
+func parseFaceSelector(c *gin.Context) (model.FaceSelector, error) {
+	var selector model.FaceSelector
+	if s := c.PostForm("face_index"); s != "" {
+		index, err := strconv.Atoi(s)
+		if err != nil {
+			return selector, errors.New("face_index should be a number")
+		}
+		selector.Index = &index
+	}
+	if s := c.PostForm("box"); s != "" {
+		parts := strings.Split(s, ",")
+		if len(parts) != 4 {
+			return selector, errors.New("box should be x0,y0,x1,y1")
+		}
+		var coords [4]int
+		for i, part := range parts {
+			var err error
+			if coords[i], err = strconv.Atoi(strings.TrimSpace(part)); err != nil {
+				return selector, errors.New("box should be x0,y0,x1,y1")
+			}
+		}
+		box := image.Rect(coords[0], coords[1], coords[2], coords[3])
+		selector.Box = &box
+	}
+	return selector, nil
+}
+
+// RemovePhoto godoc
+//
+//	@Summary		Remove photo of person
+//	@Param			name	path	string	true	"name of person"
+//	@Param			photo	path	string	true	"name of photo"
+//	@Success		204
+//	@Failure		404	{object}	string
+//	@Router			/persons/{name}/photos/{photo} [delete]
+func (service *VideoService) RemovePhoto(c *gin.Context) {
+	if err := service.vP.RemovePhoto(c.Param("name"), c.Param("photo")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetEnrollmentReport godoc
+//
+//	@Summary		Get enrollment report of gallery
+//	@Description	Return photos, which were skipped on loading of current gallery version, with reasons: no_face, face_not_selected, unreadable_image, detector_error, recognizer_error, read_error
+//	@Produce		json
+//	@Success		200	{object}	model.EnrollmentReport
+//	@Router			/gallery/report [get]
+func (service *VideoService) GetEnrollmentReport(c *gin.Context) {
+	c.JSON(http.StatusOK, service.vP.GetEnrollmentReport())
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	model "go_cv_test/internal/recognizer/app"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,6 +19,7 @@ import (
 //	@Param			id	query		int	true	"id"
 //	@Success		200	{object}	int
 //	@Failure		400	{object}	int
+//	@Failure		409	{object}	string
 //	@Router			/switch_state [post]
 func (service *VideoService) SwitchState(c *gin.Context) {
 	id, err := strconv.Atoi(c.Request.URL.Query().Get("id"))
@@ -24,7 +28,10 @@ func (service *VideoService) SwitchState(c *gin.Context) {
 		return
 	}
 	err = service.vP.SwitchState(int32(id))
-	if err != nil {
+	if errors.Is(err, model.ErrIllegalTransition) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	} else {
@@ -32,3 +39,72 @@ func (service *VideoService) SwitchState(c *gin.Context) {
 		return
 	}
 }
+
+// PauseVideo godoc
+//
+//	@Summary		Pause video processing
+//	@Description	Worker stops at the next frame and gives its slot to other videos. Only queued or processing videos can be paused
+//	@Produce		json
+//	@Param			id	path		int	true	"id"
+//	@Success		202	{object}	int
+//	@Failure		400	{object}	string
+//	@Failure		404	{object}	string
+//	@Failure		409	{object}	string
+//	@Router			/videos/{id}/pause [post]
+func (service *VideoService) PauseVideo(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	if err := service.vP.Pause(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// ResumeVideo godoc
+//
+//	@Summary		Resume video processing
+//	@Description	Paused video is put back in queue and continues from the frame it was paused on
+//	@Produce		json
+//	@Param			id	path		int	true	"id"
+//	@Success		202	{object}	int
+//	@Failure		400	{object}	string
+//	@Failure		404	{object}	string
+//	@Failure		409	{object}	string
+//	@Router			/videos/{id}/resume [post]
+func (service *VideoService) ResumeVideo(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	if err := service.vP.Resume(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// CancelVideo godoc
+//
+//	@Summary		Cancel video processing
+//	@Description	Stops processing of queued, processing or paused video, video gets status 3 - canceled
+//	@Produce		json
+//	@Param			id	path		int	true	"id"
+//	@Success		202	{object}	int
+//	@Failure		400	{object}	string
+//	@Failure		404	{object}	string
+//	@Failure		409	{object}	string
+//	@Router			/videos/{id}/cancel [post]
+func (service *VideoService) CancelVideo(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	if err := service.vP.Cancel(id, "canceled by client"); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{})
+}
@@ -14,7 +14,7 @@ import (
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	query		int	true	"id"
-//	@Success		200	{object}	model.Video
+//	@Success		200	{object}	recognizer.Video
 //	@Failure		400	{object}	string
 //	@Router			/get_status [post]
 func (service *VideoService) GetStatus(c *gin.Context) {
@@ -29,3 +29,26 @@ func (service *VideoService) GetStatus(c *gin.Context) {
 		c.JSON(http.StatusOK, video)
 	}
 }
+
+// GetHistory godoc
+//
+//	@Summary		Get status history of a video
+//	@Description	Return every status transition of video with its time and reason, from the oldest to the newest
+//	@Produce		json
+//	@Param			id	path		int	true	"id"
+//	@Success		200	{array}		recognizer.StatusChange
+//	@Failure		400	{object}	string
+//	@Failure		404	{object}	string
+//	@Router			/videos/{id}/history [get]
+func (service *VideoService) GetHistory(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	history, err := service.vP.GetHistory(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
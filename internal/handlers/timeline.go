@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	model "go_cv_test/internal/recognizer/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTimeline godoc
+//
+//	@Summary		Get appearances of persons on video
+//	@Description	Consecutive matches of the same person are merged into appearance intervals, total screen time of every person is returned as well
+//	@Produce		json
+//	@Param			id				path		int		true	"id"
+//	@Param			gap_ms			query		number	false	"matches separated by not more than this gap are merged, 2000 by default"
+//	@Param			min_duration_ms	query		number	false	"shorter appearances are dropped, 0 by default"
+//	@Success		200				{object}	model.Timeline
+//	@Failure		400				{object}	string
+//	@Failure		404				{object}	string
+//	@Router			/videos/{id}/timeline [get]
+func (service *VideoService) GetTimeline(c *gin.Context) {
+	id, ok := videoId(c)
+	if !ok {
+		return
+	}
+	gap, minDuration := model.DefaultTimelineGapMs, model.DefaultTimelineMinDurationMs
+	for param, value := range map[string]*float64{
+		"gap_ms":          &gap,
+		"min_duration_ms": &minDuration,
+	} {
+		if s := c.Query(param); s != "" {
+			var err error
+			if *value, err = strconv.ParseFloat(s, 64); err != nil || *value < 0 {
+				c.String(http.StatusBadRequest, "%s should be non-negative number", param)
+				return
+			}
+		}
+	}
+	timeline, err := service.vP.GetTimeline(id, gap, minDuration)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, timeline)
+}
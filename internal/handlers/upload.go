@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
-	"sync"
+	"strconv"
+
+	model "go_cv_test/internal/recognizer/app"
 
 	"github.com/gin-gonic/gin"
 )
@@ -29,36 +31,142 @@ func (service *VideoService) UploadHtml(c *gin.Context) {
 // UpdloadVideo godoc
 //
 //	@Summary		Upload video for processing
-//	@Description	Uploads and immidiately start video processing
+//	@Description	Uploads video and puts it in processing queue, returns id of created job without waiting for processing to finish
 //	@Accept			json
 //	@Produce		json
-//	@Param			file	formData	file	true	"file"
-//	@Success		200		{object}	string
+//	@Param			file		formData	file	true	"file"
+//	@Param			annotate	formData	bool	false	"write annotated copy of video"
+//	@Param			gallery		formData	string	false	"live - switch to changed gallery on the next frame, pinned - keep gallery taken on start"
+//	@Param			max_distance	formData	number	false	"max distance to the closest person"
+//	@Param			min_margin		formData	number	false	"min difference between distances to the second and to the closest person, for knn - between shares of their votes"
+//	@Param			max_ratio		formData	number	false	"max ratio of distances to the closest and to the second person, for knn - of votes of the second and the closest person"
+//	@Param			candidates		formData	int		false	"amount of the closest persons kept for every face"
+//	@Param			metric			formData	string	false	"euclidean or cosine"
+//	@Param			stride			formData	int		false	"analyze every n-th frame"
+//	@Param			target_fps		formData	number	false	"analyze frames with this rate"
+//	@Param			interval_ms		formData	number	false	"analyze one frame of every interval"
+//	@Param			gating			formData	string	false	"histogram - analyze frame after shot boundary, difference - analyze frame after motion"
+//	@Param			gating_threshold	formData	number	false	"share of changed pixels or distance between histograms, which starts detection"
+//	@Param			on_error		formData	string	false	"fail_fast - move video to error on failed frame, skip - skip failed frame and continue"
+//	@Param			aggregation		formData	string	false	"nearest - distance to the closest photo of person, centroid - distance to mean descriptor of person, knn - votes of the closest photos of gallery"
+//	@Success		202		{object}	int
+//	@Header			202		{string}	Location	"status resource of created job"
 //	@Failure		400		{object}	string
 //	@Router			/upload [post]
 //
 // //router.POST("/upload", func(c *gin.Context) {
 func (service *VideoService) UploadVideo(c *gin.Context) {
 	// single file
-	file, _ := c.FormFile("file")
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(http.StatusBadRequest, "unable to get file: %s", err.Error())
+		return
+	}
+	options, err := parseJobOptions(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
 	log.Println(file.Filename + " was recieved")
-	// Upload the file to specific dst.
-	filename := filepath.Base(file.Filename)
-	path := "./files/" + filename
+	// Файл сохраняется под номером задачи: задачи читают его в фоне и после перезапуска, поэтому загрузка файла
+	// с тем же именем не должна его перезаписать.
+	id := service.vP.NewJobId()
+	filename := fmt.Sprintf("%d-%s", id, filepath.Base(file.Filename))
+	path := filepath.Join(service.config.Server.UploadDir, filename)
 	if err := c.SaveUploadedFile(file, path); err != nil {
 		c.String(http.StatusBadRequest, "upload file err: %s", err.Error())
 		return
 	}
 
 	log.Printf("Start processing file...")
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	ctx, cancel := context.WithCancelCause(c.Request.Context())
-	go service.vP.RunRecognizer(ctx, cancel, path, file.Filename, &wg)
-	wg.Wait()
-	if ctx.Err() == context.Canceled {
-		c.String(http.StatusBadRequest, fmt.Sprintf("Request for '%s' was aborted due to: %s", file.Filename, ctx.Err().Error()))
-		return
+	service.vP.Submit(id, path, file.Filename, options)
+	c.Header("Location", fmt.Sprintf("/api/v1/status?id=%d", id))
+	c.JSON(http.StatusAccepted, gin.H{"id": id})
+}
+
+// reads options of job from form of upload request
+func parseJobOptions(c *gin.Context) (model.JobOptions, error) {
+	var options model.JobOptions
+	if s := c.PostForm("annotate"); s != "" {
+		var err error
+		if options.Annotate, err = strconv.ParseBool(s); err != nil {
+			return options, errors.New("annotate should be true or false")
+		}
+	}
+	switch options.Gallery = c.PostForm("gallery"); options.Gallery {
+	case "", model.GalleryLive, model.GalleryPinned:
+	default:
+		return options, fmt.Errorf("gallery should be %s or %s", model.GalleryLive, model.GalleryPinned)
+	}
+	switch options.OnError = c.PostForm("on_error"); options.OnError {
+	case "", model.ErrorPolicyFailFast, model.ErrorPolicySkip:
+	default:
+		return options, fmt.Errorf("on_error should be %s or %s", model.ErrorPolicyFailFast, model.ErrorPolicySkip)
+	}
+	switch options.Metric = c.PostForm("metric"); options.Metric {
+	case "", model.MetricEuclidean, model.MetricCosine:
+	default:
+		return options, fmt.Errorf("metric should be %s or %s", model.MetricEuclidean, model.MetricCosine)
+	}
+	switch options.Aggregation = c.PostForm("aggregation"); options.Aggregation {
+	case "", model.AggregateNearest, model.AggregateCentroid, model.AggregateKNN:
+	default:
+		return options, fmt.Errorf("aggregation should be %s, %s or %s", model.AggregateNearest, model.AggregateCentroid, model.AggregateKNN)
+	}
+	for name, field := range map[string]**float64{
+		"max_distance": &options.Thresholds.MaxDistance,
+		"min_margin":   &options.Thresholds.MinMargin,
+		"max_ratio":    &options.Thresholds.MaxRatio,
+	} {
+		if s := c.PostForm(name); s != "" {
+			value, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return options, fmt.Errorf("%s should be a number", name)
+			}
+			*field = &value
+		}
+	}
+	if err := options.Thresholds.Validate(); err != nil {
+		return options, err
+	}
+	if m := options.Thresholds.MinMargin; options.Aggregation == model.AggregateKNN && m != nil && *m > 1 {
+		return options, fmt.Errorf("%w: min_margin of knn is a share of votes, it should be in [0, 1]", model.ErrInvalidThresholds)
+	}
+	if s := c.PostForm("stride"); s != "" {
+		var err error
+		if options.Sampling.Stride, err = strconv.Atoi(s); err != nil {
+			return options, errors.New("stride should be a number")
+		}
+	}
+	for name, field := range map[string]*float64{
+		"target_fps":  &options.Sampling.TargetFPS,
+		"interval_ms": &options.Sampling.IntervalMs,
+	} {
+		if s := c.PostForm(name); s != "" {
+			var err error
+			if *field, err = strconv.ParseFloat(s, 64); err != nil {
+				return options, fmt.Errorf("%s should be a number", name)
+			}
+		}
+	}
+	if err := options.Sampling.Validate(); err != nil {
+		return options, err
+	}
+	options.Gating.Mode = c.PostForm("gating")
+	if s := c.PostForm("gating_threshold"); s != "" {
+		var err error
+		if options.Gating.Threshold, err = strconv.ParseFloat(s, 64); err != nil {
+			return options, errors.New("gating_threshold should be a number")
+		}
+	}
+	if err := options.Gating.Validate(); err != nil {
+		return options, err
+	}
+	if s := c.PostForm("candidates"); s != "" {
+		var err error
+		if options.Candidates, err = strconv.Atoi(s); err != nil || options.Candidates < 1 {
+			return options, errors.New("candidates should be a positive number")
+		}
 	}
-	c.String(http.StatusOK, fmt.Sprintf("'%s' was processed successfully!", file.Filename))
+	return options, nil
 }
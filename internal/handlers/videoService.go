@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log"
-	"runtime"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go_cv_test/internal/config"
 	model "go_cv_test/internal/recognizer/app"
 
 	"github.com/gin-gonic/gin"
@@ -12,11 +19,16 @@ import (
 )
 
 type VideoService struct {
-	vP *model.VideoProcessor
+	vP     *model.VideoProcessor
+	config config.Config
 }
 
-func GetService() VideoService {
-	return VideoService{vP: model.GetVideoProcessor(runtime.NumCPU())}
+func GetService(cfg config.Config) (VideoService, error) {
+	vP, err := model.GetVideoProcessor(cfg)
+	if err != nil {
+		return VideoService{}, err
+	}
+	return VideoService{vP: vP, config: cfg}, nil
 }
 
 //	@title			Swagger Example API
@@ -35,11 +47,11 @@ func GetService() VideoService {
 // @BasePath	/api/v1
 func (service *VideoService) Run() {
 	router := gin.Default()
-	router.Static("/static", "../web/static")
-	router.LoadHTMLFiles("../web/static/templates/main.html")
+	router.Static("/static", service.config.Server.StaticDir)
+	router.LoadHTMLFiles(service.config.Server.Template)
 	log.Printf("Videos will be proceed with %d cores", service.vP.CPUs)
 	// Set a lower memory limit for multipart forms (default is 32 MiB)
-	router.MaxMultipartMemory = 8 << 20 // 8 MiB
+	router.MaxMultipartMemory = int64(service.config.Server.MaxUploadMemoryMB) << 20
 
 	v1 := router.Group("/api/v1")
 	{
@@ -56,9 +68,63 @@ func (service *VideoService) Run() {
 		{
 			status.GET("", service.GetStatus)
 		}
+		v1.GET("/health", service.GetHealth)
+		videos := v1.Group("/videos")
+		{
+			videos.GET("", service.ListVideos)
+			videos.GET("/events", service.AllEvents)
+			videos.GET("/:id/events", service.VideoEvents)
+			videos.GET("/:id/history", service.GetHistory)
+			videos.GET("/:id/detections", service.GetDetections)
+			videos.GET("/:id/timeline", service.GetTimeline)
+			videos.GET("/:id/annotated", service.GetAnnotated)
+			videos.POST("/:id/pause", service.PauseVideo)
+			videos.POST("/:id/resume", service.ResumeVideo)
+			videos.POST("/:id/cancel", service.CancelVideo)
+		}
+		persons := v1.Group("/persons")
+		{
+			persons.GET("", service.ListPersons)
+			persons.POST("", service.CreatePerson)
+			persons.GET("/:name", service.GetPerson)
+			persons.PATCH("/:name", service.RenamePerson)
+			persons.DELETE("/:name", service.DeletePerson)
+			persons.PUT("/:name/thresholds", service.SetPersonThresholds)
+			persons.POST("/:name/photos", service.AddPhoto)
+			persons.DELETE("/:name/photos/:photo", service.RemovePhoto)
+		}
+		v1.GET("/gallery/report", service.GetEnrollmentReport)
+		// Без токена административные запросы не регистрируются, чтобы настройки не оказались открыты по ошибке.
+		if service.config.Admin.Token != "" {
+			admin := v1.Group("/admin", service.requireAdmin)
+			admin.GET("/config", service.GetConfig)
+		}
 	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	router.Run(":8080")
+	// Контекст отменяется по SIGINT или SIGTERM, вместе с ним закрываются открытые потоки событий.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{
+		Addr:        service.config.Server.Addr,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("unable to start server: %s", err.Error())
+		}
+	}()
+	<-ctx.Done()
+
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(service.config.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("unable to shutdown server gracefully: %s", err.Error())
+	}
+	// Задачи сохраняют прогресс и будут продолжены после следующего запуска.
+	if err := service.vP.Close(); err != nil {
+		log.Printf("unable to close video processor: %s", err.Error())
+	}
 }
@@ -0,0 +1,87 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"gocv.io/x/gocv"
+)
+
+// Кодек, который используется, если записать видео кодеком исходного файла не получилось.
+const fallbackCodec = "mp4v"
+
+// Красный цвет, им подписываются неизвестные лица.
+var red = color.RGBA{
+	R: 255,
+	G: 0,
+	B: 0,
+	A: 0,
+}
+
+var (
+	// ErrNotAnnotated is returned when annotated output was not requested for video
+	ErrNotAnnotated = errors.New("annotated output was not requested for video")
+	// ErrNotReady is returned when result of video is requested before video is processed
+	ErrNotReady = errors.New("video is not processed yet")
+)
+
+// opens writer of annotated copy of video in annotatedPath, source fps, frame size and codec are kept if it is possible
+func openAnnotatedWriter(video *gocv.VideoCapture, annotatedPath, videoFile string, id int32) (*gocv.VideoWriter, string, error) {
+	if err := os.MkdirAll(annotatedPath, 0750); err != nil {
+		return nil, "", err
+	}
+	output := filepath.Join(annotatedPath, fmt.Sprintf("%d%s", id, filepath.Ext(videoFile)))
+	fps := video.Get(gocv.VideoCaptureFPS)
+	width := int(video.Get(gocv.VideoCaptureFrameWidth))
+	height := int(video.Get(gocv.VideoCaptureFrameHeight))
+
+	var lastErr error
+	for _, codec := range []string{video.CodecString(), fallbackCodec} {
+		writer, err := gocv.VideoWriterFile(output, codec, fps, width, height, true)
+		if err == nil && writer.IsOpened() {
+			return writer, output, nil
+		}
+		if err == nil {
+			writer.Close()
+			err = fmt.Errorf("unable to open writer with codec %q", codec)
+		}
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// draws boxes of detected faces with names of the closest persons and distances to them, faces not matched to
+// any person are labeled as unknown without distance, it is meaningless when gallery is empty
+func annotateFrame(img *gocv.Mat, detections []FrameDetection) {
+	for _, detection := range detections {
+		label, c := "unknown", red
+		if detection.Matched {
+			label, c = fmt.Sprintf("%s (%.2f)", detection.Person, detection.Distance), blue
+		}
+		// Рисуем прямоугольник выявленного лица и пишем подпись над ним.
+		gocv.Rectangle(img, detection.Rectangle, c, 2)
+		gocv.PutText(img, label, image.Point{
+			X: detection.Rectangle.Min.X,
+			Y: detection.Rectangle.Min.Y - 5,
+		}, gocv.FontHersheyComplex, 0.6, c, 1)
+	}
+}
+
+// AnnotatedFile returns path to annotated copy of video with given id
+func (vP *VideoProcessor) AnnotatedFile(id int32) (string, error) {
+	video, err := vP.GetVideo(id)
+	if err != nil {
+		return "", err
+	}
+	if !video.Options.Annotate {
+		return "", ErrNotAnnotated
+	}
+	if video.Status != Successful || video.Annotated == "" {
+		return "", ErrNotReady
+	}
+	return video.Annotated, nil
+}
@@ -0,0 +1,250 @@
+package recognizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// batchFrame is frame kept until detection of batch. Frames, which aren't detected, are kept only to be saved and
+// written in order of video after detected frames before them, their images are kept only for annotated copy
+type batchFrame struct {
+	index     int64
+	timestamp float64
+	//it is empty for frame, which isn't detected, if annotated copy isn't written
+	img gocv.Mat
+	//frame is detected, otherwise it gets detections of the last detected frame
+	detect bool
+	//frame was gated, carried detections are saved for it, otherwise it is only annotated
+	gated bool
+}
+
+// Предел памяти копий кадров одной пачки: между анализируемыми кадрами размеченного видео в пачке копятся все
+// пропущенные кадры.
+const maxBatchBytes = 64 << 20
+
+// batch gathers sampled frames of one video, which are detected in one call of detector. Batch is detected when it
+// has size frames to detect, when its images take maxBatchBytes or when its first frame waits longer than latency
+type batch struct {
+	size    int
+	latency time.Duration
+	//images of frames, which aren't detected, are kept only to be written in annotated copy
+	annotate bool
+	frames   []batchFrame
+	detects  int
+	//memory of kept images
+	bytes   int
+	started time.Time
+}
+
+func newBatch(size int, latency time.Duration, annotate bool) *batch {
+	return &batch{size: max(1, size), latency: latency, annotate: annotate}
+}
+
+// add keeps frame, its image is copied if it is detected or written. Frame, which isn't detected, is added only after
+// some frame to detect
+func (b *batch) add(index int64, timestamp float64, img gocv.Mat, detect, gated bool) {
+	if b.detects == 0 {
+		b.started = time.Now()
+	}
+	if detect {
+		b.detects++
+	}
+	frame := batchFrame{index: index, timestamp: timestamp, detect: detect, gated: gated}
+	if b.kept(frame) {
+		frame.img = img.Clone()
+		b.bytes += img.Total() * img.ElemSize()
+	}
+	b.frames = append(b.frames, frame)
+}
+
+// returns whether image of frame is kept
+func (b *batch) kept(frame batchFrame) bool {
+	return frame.detect || b.annotate
+}
+
+func (b *batch) empty() bool {
+	return len(b.frames) == 0
+}
+
+// ready returns whether batch should be detected now
+func (b *batch) ready() bool {
+	return b.detects >= b.size || b.bytes >= maxBatchBytes || (b.detects > 0 && time.Since(b.started) >= b.latency)
+}
+
+// returns index of the first kept frame, processing is continued from it after restart
+func (b *batch) first() int64 {
+	return b.frames[0].index
+}
+
+// detect finds faces on every frame to detect, single frame isn't sent to BatchDetect
+func (b *batch) detect(m *models) ([][]face.Detection, error) {
+	images := make([]gocv.Mat, 0, b.detects)
+	for _, frame := range b.frames {
+		if frame.detect {
+			images = append(images, frame.img)
+		}
+	}
+	if len(images) == 1 {
+		detects, err := m.detector.Detect(images[0])
+		return [][]face.Detection{detects}, err
+	}
+	detects, err := m.detector.BatchDetect(images)
+	if err == nil && len(detects) != len(images) {
+		err = fmt.Errorf("detector returned results of %d frames instead of %d", len(detects), len(images))
+	}
+	return detects, err
+}
+
+// reset frees kept frames
+func (b *batch) reset() {
+	for _, frame := range b.frames {
+		if b.kept(frame) {
+			frame.img.Close()
+		}
+	}
+	b.frames, b.detects, b.bytes = nil, 0, 0
+}
+
+// frameOutput saves, publishes and annotates results of frames, which come in order of video
+type frameOutput struct {
+	vP       *VideoProcessor
+	id       int32
+	fileName string
+	//goroutine id and amount of frames for logs
+	gr    int32
+	total float64
+	//it is nil if annotated copy isn't written
+	writer *gocv.VideoWriter
+	//detections of the last detected frame, they are carried to gated frames and drawn on skipped ones
+	last []FrameDetection
+	//detections of frames, which aren't written to store yet
+	unsaved map[int64][]FrameDetection
+}
+
+// detected handles results of detected frame, frame with failed detection has no detections
+func (out *frameOutput) detected(img *gocv.Mat, frameIndex int64, frameDetections []FrameDetection) {
+	out.last = frameDetections
+	progress := float64(frameIndex) / out.total * 100
+	for i := range frameDetections {
+		detection := frameDetections[i]
+		// Если расстояние между найденным известным лицом и выявленным лицом меньше
+		// какого-то порога, то сообщаем о найденной персоне.
+		if detection.Matched {
+			log.Printf("goroutine: %d, processId: %d - %.2f%%: found %s on frame %d of %s\n", out.gr, out.id, progress, detection.Person, frameIndex, out.fileName)
+			out.vP.events.publish(Event{Type: RecognitionEvent, VideoId: out.id, At: time.Now(), Recognition: &detection})
+		}
+	}
+	out.save(frameIndex, frameDetections)
+	// Размечаем кадр только после распознавания всех лиц, чтобы рамки не попали в дескрипторы.
+	out.write(img, frameIndex, frameDetections)
+}
+
+// gated saves detections carried to gated frame, recognition events aren't published again
+func (out *frameOutput) gated(img *gocv.Mat, frameIndex int64, timestamp float64) {
+	carried := carry(out.last, frameIndex, timestamp)
+	out.save(frameIndex, carried)
+	out.write(img, frameIndex, carried)
+}
+
+// skipped writes frame skipped by sampling with boxes of the last detected frame
+func (out *frameOutput) skipped(img *gocv.Mat, frameIndex int64) {
+	out.write(img, frameIndex, out.last)
+}
+
+// save keeps detections of frame until commit
+func (out *frameOutput) save(frameIndex int64, frameDetections []FrameDetection) {
+	if len(frameDetections) == 0 {
+		return
+	}
+	if out.unsaved == nil {
+		out.unsaved = make(map[int64][]FrameDetection)
+	}
+	out.unsaved[frameIndex] = frameDetections
+}
+
+// commit writes kept detections to store in one write. It is called after every batch and before every checkpoint,
+// so checkpoint never passes frames, whose detections aren't saved
+func (out *frameOutput) commit() {
+	if len(out.unsaved) == 0 {
+		return
+	}
+	if err := out.vP.store.SaveDetections(out.id, out.unsaved); err != nil {
+		log.Printf("unable to save detections of %d frames of video %d: %s", len(out.unsaved), out.id, err.Error())
+	}
+	out.unsaved = nil
+}
+
+func (out *frameOutput) write(img *gocv.Mat, frameIndex int64, frameDetections []FrameDetection) {
+	if out.writer == nil {
+		return
+	}
+	annotateFrame(img, frameDetections)
+	if err := out.writer.Write(*img); err != nil {
+		log.Printf("unable to write annotated frame %d of video %d: %s", frameIndex, out.id, err.Error())
+	}
+}
+
+// flush detects and recognizes frames of batch with one set of models, then gives results of every kept frame to
+// output in order of video and commits them. It returns false if job was failed. If job is canceled while it waits for models,
+// checkpoint is moved back to the first frame of batch
+func (vP *VideoProcessor) flush(ctx context.Context, cancel context.CancelCauseFunc, vidInfo *Video, b *batch, gallery *GallerySnapshot, rec recognition, out *frameOutput) bool {
+	defer out.commit()
+	if b.empty() {
+		return true
+	}
+	defer b.reset()
+	// Набор моделей берётся из общего пула только на время обработки пачки.
+	m, err := vP.models.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			vidInfo.Frame = b.first()
+			return true
+		}
+		// Без моделей не обработать ни один кадр, поэтому задача завершается независимо от политики.
+		vP.fail(cancel, vidInfo, newJobError(StageModels, b.first(), err))
+		return false
+	}
+	detects, detectErr := b.detect(m)
+	results := make([][]FrameDetection, 0, b.detects)
+	errs := make([]error, 0, b.detects)
+	for _, frame := range b.frames {
+		if !frame.detect {
+			continue
+		}
+		if detectErr != nil {
+			results, errs = append(results, nil), append(errs, newJobError(StageDetect, frame.index, detectErr))
+			continue
+		}
+		frameDetections, err := recognizeFaces(m, frame.img, detects[len(results)], gallery, rec, frame.index, frame.timestamp)
+		results, errs = append(results, frameDetections), append(errs, err)
+	}
+	vP.models.Release(m)
+
+	detected := 0
+	for i := range b.frames {
+		frame := &b.frames[i]
+		switch {
+		case frame.detect:
+			frameDetections, err := results[detected], errs[detected]
+			detected++
+			// Пропущенный кадр попадает в размеченную копию без рамок.
+			var jobErr *JobError
+			if errors.As(err, &jobErr) && !vP.frameFailed(cancel, vidInfo, jobErr) {
+				return false
+			}
+			out.detected(&frame.img, frame.index, frameDetections)
+		case frame.gated:
+			out.gated(&frame.img, frame.index, frame.timestamp)
+		default:
+			out.skipped(&frame.img, frame.index)
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package recognizer
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Количество кадров видео, на которых сравниваются размеры пачек.
+const benchFrames = 16
+
+// reads the first frames of video given by GCV_BENCH_VIDEO and loads models from GCV_BENCH_MODELS or from models
+// folder of package. Benchmark is skipped without them
+func benchInput(b *testing.B) (*models, []gocv.Mat) {
+	file := os.Getenv("GCV_BENCH_VIDEO")
+	if file == "" {
+		b.Skip("GCV_BENCH_VIDEO isn't set")
+	}
+	modelsPath := os.Getenv("GCV_BENCH_MODELS")
+	if modelsPath == "" {
+		modelsPath = "models"
+	}
+	m, err := loadModels(modelsPath)
+	if err != nil {
+		b.Skip(err)
+	}
+	b.Cleanup(m.close)
+	video, err := gocv.VideoCaptureFile(file)
+	if err != nil {
+		b.Skip(err)
+	}
+	defer video.Close()
+	var frames []gocv.Mat
+	for len(frames) < benchFrames {
+		img := gocv.NewMat()
+		if !video.Read(&img) || img.Empty() {
+			img.Close()
+			break
+		}
+		frames = append(frames, img)
+	}
+	b.Cleanup(func() {
+		for _, img := range frames {
+			img.Close()
+		}
+	})
+	if len(frames) == 0 {
+		b.Skip("video has no frames")
+	}
+	return m, frames
+}
+
+// images of frames, which aren't detected, are kept only for annotated copy, and batch is ready when its images take
+// too much memory
+func TestBatchMemory(t *testing.T) {
+	img := gocv.NewMatWithSize(1080, 1920, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	for _, annotate := range []bool{false, true} {
+		pending := newBatch(4, time.Hour, annotate)
+		pending.add(0, 0, img, true, false)
+		for i := int64(1); i < 30; i++ {
+			pending.add(i, float64(i*40), img, false, i%2 == 0)
+		}
+		for _, frame := range pending.frames {
+			if kept := !frame.img.Empty(); kept != (frame.detect || annotate) {
+				t.Errorf("annotate %v: image of frame %d is kept: %v", annotate, frame.index, kept)
+			}
+		}
+		frameBytes := img.Total() * img.ElemSize()
+		if want := frameBytes; !annotate && pending.bytes != want {
+			t.Errorf("batch keeps %d bytes, want %d", pending.bytes, want)
+		}
+		if want := maxBatchBytes/frameBytes + 1; annotate && pending.ready() != (len(pending.frames) >= want) {
+			t.Errorf("batch of %d frames of %d bytes is ready: %v", len(pending.frames), frameBytes, pending.ready())
+		}
+		if !annotate && pending.ready() {
+			t.Error("batch without annotation is ready before size or latency")
+		}
+		pending.reset()
+		if !pending.empty() || pending.bytes != 0 || pending.detects != 0 {
+			t.Errorf("reset kept %d frames", len(pending.frames))
+		}
+	}
+}
+
+// Batch of size 1 is detected by Detect, so it is the single-frame path
+func BenchmarkBatchDetect(b *testing.B) {
+	m, frames := benchInput(b)
+	for _, size := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			pending := newBatch(size, time.Hour, false)
+			defer pending.reset()
+			for i := 0; i < b.N; i++ {
+				for _, img := range frames {
+					pending.add(0, 0, img, true, false)
+					if !pending.ready() {
+						continue
+					}
+					if _, err := pending.detect(m); err != nil {
+						b.Fatal(err)
+					}
+					pending.reset()
+				}
+				if !pending.empty() {
+					if _, err := pending.detect(m); err != nil {
+						b.Fatal(err)
+					}
+					pending.reset()
+				}
+			}
+			b.ReportMetric(float64(b.N*len(frames))/b.Elapsed().Seconds(), "frames/s")
+		})
+	}
+}
+
+// Faces of the most crowded frame are recognized one by one and in one pass of network
+func BenchmarkRecognizeFaces(b *testing.B) {
+	m, frames := benchInput(b)
+	var img gocv.Mat
+	var rects []image.Rectangle
+	for _, frame := range frames {
+		detects, err := m.detector.Detect(frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(detects) > len(rects) {
+			img, rects = frame, nil
+			for _, detect := range detects {
+				rects = append(rects, detect.Rectangle)
+			}
+		}
+	}
+	if len(rects) == 0 {
+		b.Skip("frames have no faces")
+	}
+	b.Run(fmt.Sprintf("single/%d", len(rects)), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, rect := range rects {
+				if _, err := m.recognize(img, rect); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run(fmt.Sprintf("batch/%d", len(rects)), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := m.recognizeAll(img, rects); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
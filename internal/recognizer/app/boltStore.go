@@ -0,0 +1,222 @@
+package recognizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	videosBucket     = []byte("videos")
+	historyBucket    = []byte("history")
+	detectionsBucket = []byte("detections")
+)
+
+// BoltStore is JobStore, which keeps videos in BoltDB file, so they survive restart of the service.
+// Every video is stored as json under its id, status history is stored in separate bucket under id and sequence number,
+// detections are stored under id, frame and index of detection on frame
+type BoltStore struct {
+	db *bolt.DB
+	// bolt allows only one writer at a time anyway, mutex keeps notifications in the same order as writes
+	mu       sync.Mutex
+	watchers watchers
+}
+
+// OpenBoltStore opens (or creates) database file at given path
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{videosBucket, historyBucket, detectionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func idKey(id int32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(id))
+	return key
+}
+
+func (s *BoltStore) Get(id int32) (Video, error) {
+	var video Video
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(videosBucket).Get(idKey(id))
+		if data == nil {
+			return ErrVideoNotFound
+		}
+		return json.Unmarshal(data, &video)
+	})
+	return video, err
+}
+
+func (s *BoltStore) List() []Video {
+	var videos []Video
+	// keys are big endian ids, so cursor already walks in id order
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(videosBucket).ForEach(func(_, data []byte) error {
+			var video Video
+			if err := json.Unmarshal(data, &video); err != nil {
+				return err
+			}
+			videos = append(videos, video)
+			return nil
+		})
+	})
+	return videos
+}
+
+func (s *BoltStore) Update(id int32, fn func(video *Video)) (Video, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var video Video
+	var statusChanged bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		videos := tx.Bucket(videosBucket)
+		data := videos.Get(idKey(id))
+		created := data == nil
+		video = Video{Id: id}
+		if !created {
+			if err := json.Unmarshal(data, &video); err != nil {
+				return err
+			}
+		}
+		stored := video
+		fn(&video)
+		video.Id = id
+		previous := stored.Status
+		if created {
+			previous = video.Status
+		} else if err := checkTransition(previous, video.Status); err != nil {
+			return err
+		}
+		stamp(&video, stored, created, time.Now())
+		data, err := json.Marshal(video)
+		if err != nil {
+			return err
+		}
+		if err := videos.Put(idKey(id), data); err != nil {
+			return err
+		}
+		statusChanged = created || previous != video.Status
+		if statusChanged {
+			return s.appendHistory(tx, id, newStatusChange(previous, video))
+		}
+		return nil
+	})
+	if err != nil {
+		return Video{}, err
+	}
+	s.watchers.notify(video, statusChanged)
+	return video, nil
+}
+
+func (s *BoltStore) appendHistory(tx *bolt.Tx, id int32, change StatusChange) error {
+	history := tx.Bucket(historyBucket)
+	seq, err := history.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return history.Put(binary.BigEndian.AppendUint64(idKey(id), seq), data)
+}
+
+func (s *BoltStore) Watch(ctx context.Context) <-chan Video {
+	return s.watchers.watch(ctx)
+}
+
+func (s *BoltStore) History(id int32) ([]StatusChange, error) {
+	var history []StatusChange
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := idKey(id)
+		c := tx.Bucket(historyBucket).Cursor()
+		for k, data := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, data = c.Next() {
+			var change StatusChange
+			if err := json.Unmarshal(data, &change); err != nil {
+				return err
+			}
+			history = append(history, change)
+		}
+		return nil
+	})
+	if err == nil && history == nil {
+		err = ErrVideoNotFound
+	}
+	return history, err
+}
+
+// SaveDetections writes every frame in one transaction, so batch of frames costs one sync of database
+func (s *BoltStore) SaveDetections(id int32, frames map[int64][]FrameDetection) error {
+	if len(frames) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(detectionsBucket)
+		for frame, detections := range frames {
+			prefix := binary.BigEndian.AppendUint64(idKey(id), uint64(frame))
+			c := bucket.Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+			for i, detection := range detections {
+				data, err := json.Marshal(detection)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(binary.BigEndian.AppendUint32(prefix, uint32(i)), data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Detections(id int32, filter DetectionFilter) ([]FrameDetection, error) {
+	var detections []FrameDetection
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := idKey(id)
+		c := tx.Bucket(detectionsBucket).Cursor()
+		for k, data := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, data = c.Next() {
+			var detection FrameDetection
+			if err := json.Unmarshal(data, &detection); err != nil {
+				return err
+			}
+			if filter.matches(detection) {
+				detections = append(detections, detection)
+			}
+		}
+		return nil
+	})
+	return detections, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
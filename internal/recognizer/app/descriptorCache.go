@@ -0,0 +1,184 @@
+package recognizer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// Версия формата файла кэша. При изменении формата её нужно увеличить, тогда старый кэш будет пересчитан.
+const descriptorCacheVersion = 1
+
+var descriptorCacheMagic = [4]byte{'G', 'C', 'V', 'D'}
+
+// descriptorKey identifies descriptor of gallery image: the same image gives the same descriptor only with the same
+// models and the same parameters of vectorization
+type descriptorKey struct {
+	//sha256 of content of image file
+	Image [sha256.Size]byte
+	//sha256 of model files, see ModelPool.Checksum
+	Models    [sha256.Size]byte
+	Padding   float64
+	Jittering int32
+}
+
+// file is a header followed by Count entries, every number is little endian
+type descriptorCacheHeader struct {
+	Magic   [4]byte
+	Version uint32
+	Count   uint32
+}
+
+type descriptorCacheEntry struct {
+	Key        descriptorKey
+	Descriptor face.Descriptor
+}
+
+// descriptorCache keeps descriptors of gallery images on disk, so they are computed only for new or changed images.
+// Entries computed by other models are dropped on load, so cache is invalidated when any model file changes
+type descriptorCache struct {
+	path   string
+	models [sha256.Size]byte
+	//parameters of vectorization
+	padding   float64
+	jittering int32
+
+	mu      sync.Mutex
+	entries map[descriptorKey]face.Descriptor
+	//keys requested since the last compact
+	used  map[descriptorKey]struct{}
+	dirty bool
+}
+
+// openDescriptorCache reads cache from path. Missing, damaged or outdated file gives empty cache, it is rewritten on save
+func openDescriptorCache(path string, models [sha256.Size]byte, padding float64, jittering int) (*descriptorCache, error) {
+	c := &descriptorCache{
+		path:      path,
+		models:    models,
+		padding:   padding,
+		jittering: int32(jittering),
+		entries:   make(map[descriptorKey]face.Descriptor),
+		used:      make(map[descriptorKey]struct{})}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var header descriptorCacheHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return c, fmt.Errorf("unable to read descriptor cache header: %w", err)
+	}
+	if header.Magic != descriptorCacheMagic {
+		return c, fmt.Errorf("%s is not a descriptor cache", path)
+	}
+	if header.Version != descriptorCacheVersion {
+		return c, fmt.Errorf("descriptor cache has version %d, expected %d", header.Version, descriptorCacheVersion)
+	}
+	for i := uint32(0); i < header.Count; i++ {
+		var entry descriptorCacheEntry
+		if err := binary.Read(r, binary.LittleEndian, &entry); err != nil {
+			c.entries = make(map[descriptorKey]face.Descriptor)
+			return c, fmt.Errorf("unable to read descriptor cache entry %d: %w", i, err)
+		}
+		if entry.Key.Models != models {
+			c.dirty = true
+			continue
+		}
+		c.entries[entry.Key] = entry.Descriptor
+	}
+	return c, nil
+}
+
+// key returns key of image with given content for current models and vectorization parameters
+func (c *descriptorCache) key(image []byte) descriptorKey {
+	return descriptorKey{Image: sha256.Sum256(image), Models: c.models, Padding: c.padding, Jittering: c.jittering}
+}
+
+func (c *descriptorCache) get(key descriptorKey) (face.Descriptor, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	descriptor, ok := c.entries[key]
+	if ok {
+		c.used[key] = struct{}{}
+	}
+	return descriptor, ok
+}
+
+func (c *descriptorCache) put(key descriptorKey, descriptor face.Descriptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = descriptor
+	c.used[key] = struct{}{}
+	c.dirty = true
+}
+
+// compact drops entries not requested since the previous compact, it is called after loading of the whole gallery,
+// so descriptors of removed images don't pile up
+func (c *descriptorCache) compact() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if _, ok := c.used[key]; !ok {
+			delete(c.entries, key)
+			c.dirty = true
+		}
+	}
+	c.used = make(map[descriptorKey]struct{})
+}
+
+// save writes cache to disk if it was changed. File is replaced atomically, so cache is never left half-written
+func (c *descriptorCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := c.write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (c *descriptorCache) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	header := descriptorCacheHeader{Magic: descriptorCacheMagic, Version: descriptorCacheVersion, Count: uint32(len(c.entries))}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	for key, descriptor := range c.entries {
+		if err := binary.Write(bw, binary.LittleEndian, descriptorCacheEntry{Key: key, Descriptor: descriptor}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
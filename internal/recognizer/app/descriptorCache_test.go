@@ -0,0 +1,195 @@
+package recognizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+var (
+	testModels  = sha256.Sum256([]byte("models"))
+	otherModels = sha256.Sum256([]byte("other models"))
+)
+
+// returns descriptor filled with given value
+func descriptorOf(value float32) face.Descriptor {
+	var d face.Descriptor
+	for i := range d {
+		d[i] = value
+	}
+	return d
+}
+
+// opens cache and fails test on error
+func openCache(t *testing.T, path string, models [sha256.Size]byte) *descriptorCache {
+	t.Helper()
+	c, err := openDescriptorCache(path, models, 0.2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// returns cache file with two entries of testModels and keys of these entries
+func savedCache(t *testing.T) (string, []descriptorKey) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache", "descriptors.bin")
+	c := openCache(t, path, testModels)
+	keys := []descriptorKey{c.key([]byte("first")), c.key([]byte("second"))}
+	for i, key := range keys {
+		c.put(key, descriptorOf(float32(i+1)))
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	return path, keys
+}
+
+func TestDescriptorCacheRoundTrip(t *testing.T) {
+	path, keys := savedCache(t)
+	c := openCache(t, path, testModels)
+	if len(c.entries) != len(keys) {
+		t.Fatalf("cache has %d entries, want %d", len(c.entries), len(keys))
+	}
+	for i, key := range keys {
+		descriptor, ok := c.get(key)
+		if !ok || descriptor != descriptorOf(float32(i+1)) {
+			t.Errorf("entry %d was read as %v, %v", i, descriptor[0], ok)
+		}
+	}
+	//the same image vectorized with other parameters isn't found
+	other, err := openDescriptorCache(path, testModels, 0.3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.get(other.key([]byte("first"))); ok {
+		t.Error("descriptor of other padding was found")
+	}
+
+	//unchanged cache isn't rewritten
+	before, _ := os.Stat(path)
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("unchanged cache was rewritten")
+	}
+}
+
+func TestDescriptorCacheMissingFile(t *testing.T) {
+	c := openCache(t, filepath.Join(t.TempDir(), "descriptors.bin"), testModels)
+	if len(c.entries) != 0 {
+		t.Errorf("missing file gave %d entries", len(c.entries))
+	}
+}
+
+// damaged or outdated file gives error and empty cache, which replaces file on save
+func TestDescriptorCacheDiscarded(t *testing.T) {
+	path, keys := savedCache(t)
+	valid, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := func(magic [4]byte, version uint32, count uint32) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, descriptorCacheHeader{Magic: magic, Version: version, Count: count})
+		return buf.Bytes()
+	}
+	headerSize := binary.Size(descriptorCacheHeader{})
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"version mismatch", append(header(descriptorCacheMagic, descriptorCacheVersion+1, 2), valid[headerSize:]...)},
+		{"old version", append(header(descriptorCacheMagic, 0, 2), valid[headerSize:]...)},
+		{"other magic", append(header([4]byte{'G', 'C', 'V', 'X'}, descriptorCacheVersion, 2), valid[headerSize:]...)},
+		{"short header", valid[:headerSize-1]},
+		{"truncated entry", valid[:len(valid)-1]},
+		{"more entries in header", append(header(descriptorCacheMagic, descriptorCacheVersion, 3), valid[headerSize:]...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.WriteFile(path, test.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			c, err := openDescriptorCache(path, testModels, 0.2, 1)
+			if err == nil {
+				t.Error("damaged cache was opened without error")
+			}
+			if c == nil || len(c.entries) != 0 {
+				t.Fatalf("damaged cache gave entries %v", c)
+			}
+			c.put(keys[0], descriptorOf(5))
+			if err := c.save(); err != nil {
+				t.Fatal(err)
+			}
+			c = openCache(t, path, testModels)
+			if descriptor, ok := c.get(keys[0]); !ok || descriptor != descriptorOf(5) || len(c.entries) != 1 {
+				t.Errorf("rewritten cache has %d entries", len(c.entries))
+			}
+		})
+	}
+}
+
+// entries computed by other models are dropped on load and aren't written back
+func TestDescriptorCacheOtherModels(t *testing.T) {
+	path, keys := savedCache(t)
+	c := openCache(t, path, otherModels)
+	if len(c.entries) != 0 {
+		t.Fatalf("cache of other models has %d entries", len(c.entries))
+	}
+	key := c.key([]byte("first"))
+	if key == keys[0] {
+		t.Fatal("key doesn't depend on models")
+	}
+	c.put(key, descriptorOf(3))
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = openCache(t, path, testModels)
+	if len(c.entries) != 0 {
+		t.Errorf("entries of replaced models survived: %d", len(c.entries))
+	}
+	c = openCache(t, path, otherModels)
+	if descriptor, ok := c.get(key); !ok || descriptor != descriptorOf(3) || len(c.entries) != 1 {
+		t.Errorf("cache of new models has %d entries", len(c.entries))
+	}
+}
+
+func TestDescriptorCacheCompact(t *testing.T) {
+	path, keys := savedCache(t)
+	c := openCache(t, path, testModels)
+	//only the first image is still in gallery, and the new one is added
+	c.get(keys[0])
+	added := c.key([]byte("third"))
+	c.put(added, descriptorOf(3))
+	c.compact()
+	if _, ok := c.entries[keys[1]]; ok || len(c.entries) != 2 {
+		t.Errorf("compact left %d entries", len(c.entries))
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = openCache(t, path, testModels)
+	for _, key := range []descriptorKey{keys[0], added} {
+		if _, ok := c.get(key); !ok {
+			t.Error("used entry was dropped")
+		}
+	}
+	if _, ok := c.get(keys[1]); ok {
+		t.Error("unused entry was saved")
+	}
+	//requests before compact are forgotten by it
+	c.compact()
+	c.compact()
+	if len(c.entries) != 0 {
+		t.Errorf("second compact without requests left %d entries", len(c.entries))
+	}
+}
@@ -0,0 +1,64 @@
+package recognizer
+
+import (
+	"image"
+	"strings"
+)
+
+// FrameDetection is a face found on frame of video together with the closest known persons
+type FrameDetection struct {
+	//0-based index of frame
+	Frame int64 `json:"frame"`
+	//position of frame in video
+	TimestampMs float64         `json:"timestamp_ms"`
+	Rectangle   image.Rectangle `json:"rectangle" swaggertype:"object"`
+	//confidence of face detector
+	Confidence float64 `json:"confidence"`
+	//the closest person and distance to it
+	Person   string  `json:"person"`
+	Distance float64 `json:"distance"`
+	//calibrated confidence of the closest person
+	MatchConfidence float64 `json:"match_confidence"`
+	//whether the closest person passed every test of thresholds
+	Matched bool `json:"matched"`
+	//name of matched person or Unknown
+	Label string `json:"label"`
+	//test, which the closest person failed: distance, margin or ratio
+	Rejection string `json:"rejection,omitempty"`
+	//the second closest person, it is empty if gallery has only one person
+	RunnerUp         string  `json:"runner_up,omitempty"`
+	RunnerUpDistance float64 `json:"runner_up_distance,omitempty"`
+	//the closest persons ordered by distance
+	Candidates []Match `json:"candidates,omitempty"`
+	//detection is copied from the last analyzed frame, because frame was gated, see JobOptions.Gating
+	Carried bool `json:"carried,omitempty"`
+}
+
+// DetectionFilter describes which detections should be returned. Zero values of fields match every detection
+type DetectionFilter struct {
+	//name of the closest person, it is matched case-insensitively
+	Person string
+	//bounds of frame timestamp, ToMs is inclusive
+	FromMs float64
+	ToMs   float64
+	//minimal confidence of face detector
+	MinConfidence float64
+	//return only detections matched to person
+	MatchedOnly bool
+}
+
+func (filter DetectionFilter) matches(detection FrameDetection) bool {
+	if filter.Person != "" && !strings.EqualFold(filter.Person, detection.Person) {
+		return false
+	}
+	if detection.TimestampMs < filter.FromMs {
+		return false
+	}
+	if filter.ToMs > 0 && detection.TimestampMs > filter.ToMs {
+		return false
+	}
+	if detection.Confidence < filter.MinConfidence {
+		return false
+	}
+	return !filter.MatchedOnly || detection.Matched
+}
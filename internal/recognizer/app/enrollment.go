@@ -0,0 +1,155 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"time"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// ProblemKind is a reason, why photo of person wasn't enrolled
+type ProblemKind string
+
+const (
+	NoFace          ProblemKind = "no_face"
+	FaceNotSelected ProblemKind = "face_not_selected"
+	UnreadableImage ProblemKind = "unreadable_image"
+	DetectorError   ProblemKind = "detector_error"
+	RecognizerError ProblemKind = "recognizer_error"
+	//folder of person or the whole gallery can't be read
+	ReadError ProblemKind = "read_error"
+	//thresholds file of person can't be parsed, person is matched with thresholds of job
+	InvalidThresholds ProblemKind = "invalid_thresholds"
+)
+
+// EnrollmentProblem describes file of gallery, which was skipped during enrollment
+type EnrollmentProblem struct {
+	//empty for problems of the whole gallery
+	Person string `json:"person,omitempty"`
+	//empty for problems of folder of person
+	Photo   string      `json:"photo,omitempty"`
+	Kind    ProblemKind `json:"kind"`
+	Message string      `json:"message"`
+}
+
+// EnrollmentReport describes enrollment of gallery snapshot with the same version
+type EnrollmentReport struct {
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	//amount of enrolled persons and photos
+	Persons  int                 `json:"persons"`
+	Photos   int                 `json:"photos"`
+	Problems []EnrollmentProblem `json:"problems"`
+}
+
+// returns kind of error returned by enrollPhoto
+func problemKind(err error) ProblemKind {
+	switch {
+	case errors.Is(err, ErrNoFace):
+		return NoFace
+	case errors.Is(err, ErrFaceNotSelected):
+		return FaceNotSelected
+	case errors.Is(err, ErrUnreadableImage):
+		return UnreadableImage
+	case errors.Is(err, ErrDetectorFailed):
+		return DetectorError
+	case errors.Is(err, ErrRecognizerFailed):
+		return RecognizerError
+	case errors.Is(err, ErrInvalidThresholds):
+		return InvalidThresholds
+	default:
+		return ReadError
+	}
+}
+
+func newProblem(person, photo string, err error) EnrollmentProblem {
+	return EnrollmentProblem{Person: person, Photo: photo, Kind: problemKind(err), Message: err.Error()}
+}
+
+// GetEnrollmentReport returns problems of enrollment of current gallery snapshot
+func (vP *VideoProcessor) GetEnrollmentReport() EnrollmentReport {
+	return vP.gallery.Snapshot().Report
+}
+
+// FaceSelector chooses face of group photo, which is enrolled. Box has priority over Index,
+// if none of them is set, the largest and the most confident face is chosen
+type FaceSelector struct {
+	//index of face in list of detected faces
+	Index *int
+	//face overlapping this box the most is chosen
+	Box *image.Rectangle
+}
+
+// DetectedFace is a face found on enrolled photo
+type DetectedFace struct {
+	Index      int             `json:"index"`
+	Rectangle  image.Rectangle `json:"rectangle" swaggertype:"object"`
+	Confidence float64         `json:"confidence"`
+	//face was enrolled
+	Selected bool `json:"selected"`
+}
+
+// Enrollment is a result of adding photo to gallery, it contains every detected face, so client is able to choose
+// another face and add photo again
+type Enrollment struct {
+	Photo PhotoInfo      `json:"photo"`
+	Faces []DetectedFace `json:"faces"`
+}
+
+// returns index of face chosen by selector
+func selectFace(detects []face.Detection, selector FaceSelector) (int, error) {
+	if len(detects) == 0 {
+		return 0, ErrNoFace
+	}
+	switch {
+	case selector.Box != nil:
+		best, bestOverlap := -1, 0.0
+		for i, detect := range detects {
+			if overlap := intersectionOverUnion(detect.Rectangle, *selector.Box); overlap > bestOverlap {
+				best, bestOverlap = i, overlap
+			}
+		}
+		if best < 0 {
+			return 0, fmt.Errorf("%w: no face overlaps box %v", ErrFaceNotSelected, *selector.Box)
+		}
+		return best, nil
+	case selector.Index != nil:
+		if *selector.Index < 0 || *selector.Index >= len(detects) {
+			return 0, fmt.Errorf("%w: face %d is requested, but %d faces are detected", ErrFaceNotSelected, *selector.Index, len(detects))
+		}
+		return *selector.Index, nil
+	}
+	//area is weighted by confidence, so big but doubtful detection doesn't win over clear face
+	best, bestScore := 0, -1.0
+	for i, detect := range detects {
+		size := detect.Rectangle.Size()
+		if score := float64(size.X*size.Y) * detect.Confidence; score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, nil
+}
+
+func intersectionOverUnion(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b).Size()
+	intersectionArea := float64(intersection.X * intersection.Y)
+	if intersectionArea == 0 {
+		return 0
+	}
+	sizeA, sizeB := a.Size(), b.Size()
+	return intersectionArea / (float64(sizeA.X*sizeA.Y) + float64(sizeB.X*sizeB.Y) - intersectionArea)
+}
+
+func detectedFaces(detects []face.Detection, selected int) []DetectedFace {
+	faces := make([]DetectedFace, 0, len(detects))
+	for i, detect := range detects {
+		faces = append(faces, DetectedFace{
+			Index:      i,
+			Rectangle:  detect.Rectangle,
+			Confidence: detect.Confidence,
+			Selected:   i == selected})
+	}
+	return faces
+}
@@ -0,0 +1,96 @@
+package recognizer
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+func index(i int) *int {
+	return &i
+}
+
+func box(x0, y0, x1, y1 int) *image.Rectangle {
+	r := image.Rect(x0, y0, x1, y1)
+	return &r
+}
+
+func TestSelectFace(t *testing.T) {
+	//big doubtful face, small clear face, middle clear face
+	detects := []face.Detection{
+		{Rectangle: image.Rect(0, 0, 200, 200), Confidence: 0.1},
+		{Rectangle: image.Rect(300, 0, 340, 40), Confidence: 0.99},
+		{Rectangle: image.Rect(0, 300, 100, 400), Confidence: 0.9},
+	}
+	tests := []struct {
+		name     string
+		detects  []face.Detection
+		selector FaceSelector
+		want     int
+		err      error
+	}{
+		{"no faces", nil, FaceSelector{}, 0, ErrNoFace},
+		{"no faces with index", nil, FaceSelector{Index: index(0)}, 0, ErrNoFace},
+		{"single face", detects[:1], FaceSelector{}, 0, nil},
+		//area weighted by confidence: 4000, 1584, 9000
+		{"largest confident face", detects, FaceSelector{}, 2, nil},
+		{"confident face beats doubtful", detects[:2], FaceSelector{}, 0, nil},
+		{"equal faces", []face.Detection{detects[2], detects[2]}, FaceSelector{}, 0, nil},
+		{"first index", detects, FaceSelector{Index: index(0)}, 0, nil},
+		{"last index", detects, FaceSelector{Index: index(2)}, 2, nil},
+		{"index out of range", detects, FaceSelector{Index: index(3)}, 0, ErrFaceNotSelected},
+		{"negative index", detects, FaceSelector{Index: index(-1)}, 0, ErrFaceNotSelected},
+		{"box around face", detects, FaceSelector{Box: box(290, 0, 350, 50)}, 1, nil},
+		//box touches two faces, it overlaps the second one more
+		{"box overlapping two faces", detects, FaceSelector{Box: box(0, 150, 100, 400)}, 2, nil},
+		{"box inside big face", detects, FaceSelector{Box: box(50, 50, 100, 100)}, 0, nil},
+		{"box outside of faces", detects, FaceSelector{Box: box(500, 500, 600, 600)}, 0, ErrFaceNotSelected},
+		{"box touching face edge", detects, FaceSelector{Box: box(340, 0, 400, 40)}, 0, ErrFaceNotSelected},
+		{"box wins over index", detects, FaceSelector{Index: index(0), Box: box(300, 0, 340, 40)}, 1, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := selectFace(test.detects, test.selector)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("selectFace returned %d, %v, want %v", got, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("selectFace returned %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIntersectionOverUnion(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b image.Rectangle
+		want float64
+	}{
+		{"equal", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{"half shifted", image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 50.0 / 150},
+		{"inside", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 5, 5), 0.25},
+		{"corner", image.Rect(0, 0, 10, 10), image.Rect(5, 5, 15, 15), 25.0 / 175},
+		{"touching", image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10), 0},
+		{"apart", image.Rect(0, 0, 10, 10), image.Rect(50, 50, 60, 60), 0},
+		{"empty", image.Rect(0, 0, 10, 10), image.Rectangle{}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, got := range []float64{intersectionOverUnion(test.a, test.b), intersectionOverUnion(test.b, test.a)} {
+				if math.Abs(got-test.want) > 1e-9 {
+					t.Errorf("intersectionOverUnion = %g, want %g", got, test.want)
+				}
+			}
+		})
+	}
+}
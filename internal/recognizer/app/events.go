@@ -0,0 +1,110 @@
+package recognizer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	// video changed its status
+	StatusEvent EventType = "status"
+	// video processing moved forward, these events are throttled for every subscriber
+	ProgressEvent EventType = "progress"
+	// known person was found on frame
+	RecognitionEvent EventType = "recognition"
+)
+
+// default interval between two progress events of one video
+const DefaultProgressInterval = time.Second
+
+// size of buffer of every subscriber, progress and recognition events are dropped for subscribers which are not able
+// to keep up, status events are never dropped
+const eventBuffer = 256
+
+// Event is sent to subscribers of video events
+type Event struct {
+	Type        EventType       `json:"type"`
+	VideoId     int32           `json:"video_id"`
+	At          time.Time       `json:"at"`
+	Video       *Video          `json:"video,omitempty"`
+	Recognition *FrameDetection `json:"recognition,omitempty"`
+}
+
+type subscriber struct {
+	//0 means events of every video
+	videoId          int32
+	progressInterval time.Duration
+	lastProgress     map[int32]time.Time
+	events           *mailbox[Event]
+}
+
+// eventHub delivers events of videos to subscribers. Status and progress events are made from updates of JobStore,
+// recognition events are published by workers
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[*subscriber]struct{})}
+}
+
+// run converts video updates to events until updates channel is closed
+func (h *eventHub) run(updates <-chan Video) {
+	statuses := make(map[int32]VideoStatus)
+	for video := range updates {
+		eventType := ProgressEvent
+		if status, ok := statuses[video.Id]; !ok || status != video.Status {
+			eventType = StatusEvent
+			statuses[video.Id] = video.Status
+		}
+		if video.Status.IsFinal() {
+			delete(statuses, video.Id)
+		}
+		video := video
+		h.publish(Event{Type: eventType, VideoId: video.Id, At: video.UpdatedAt, Video: &video})
+	}
+}
+
+// subscribe returns channel of events of video with given id, or of every video if id is 0.
+// Channel is closed when ctx is done
+func (h *eventHub) subscribe(ctx context.Context, videoId int32, progressInterval time.Duration) <-chan Event {
+	sub := &subscriber{
+		videoId:          videoId,
+		progressInterval: progressInterval,
+		lastProgress:     make(map[int32]time.Time),
+		events:           newMailbox[Event](eventBuffer)}
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		sub.events.run(ctx)
+		h.mu.Lock()
+		delete(h.subscribers, sub)
+		h.mu.Unlock()
+	}()
+	return sub.events.ch
+}
+
+func (h *eventHub) publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if sub.videoId != 0 && sub.videoId != event.VideoId {
+			continue
+		}
+		if event.Type == StatusEvent && event.Video.Status.IsFinal() {
+			delete(sub.lastProgress, event.VideoId)
+		}
+		if event.Type == ProgressEvent {
+			if event.At.Sub(sub.lastProgress[event.VideoId]) < sub.progressInterval {
+				continue
+			}
+			sub.lastProgress[event.VideoId] = event.At
+		}
+		sub.events.push(event, event.Type == StatusEvent)
+	}
+}
@@ -0,0 +1,93 @@
+package recognizer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// subscriber, which doesn't read, loses progress and recognition events, but gets every status event
+func TestEventHubKeepsStatusEvents(t *testing.T) {
+	h := newEventHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := h.subscribe(ctx, 1, 0)
+	other := h.subscribe(ctx, 2, 0)
+
+	at := time.Now()
+	status := func(status VideoStatus) Event {
+		at = at.Add(time.Millisecond)
+		return Event{Type: StatusEvent, VideoId: 1, At: at, Video: &Video{Id: 1, Status: status}}
+	}
+	h.publish(status(Processing))
+	for i := 0; i < 2*eventBuffer; i++ {
+		at = at.Add(time.Millisecond)
+		h.publish(Event{Type: ProgressEvent, VideoId: 1, At: at, Video: &Video{Id: 1, Status: Processing}})
+		h.publish(Event{Type: RecognitionEvent, VideoId: 1, At: at, Recognition: &FrameDetection{}})
+	}
+	h.publish(status(Error))
+
+	statuses, received := 0, 0
+	for done := false; !done; {
+		select {
+		case event := <-events:
+			received++
+			if event.VideoId != 1 {
+				t.Fatalf("subscriber of video 1 got event of video %d", event.VideoId)
+			}
+			if event.Type == StatusEvent {
+				statuses++
+				done = event.Video.Status == Error
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("final status event wasn't sent, got %d events", received)
+		}
+	}
+	if statuses != 2 {
+		t.Errorf("subscriber got %d status events, want 2", statuses)
+	}
+	if received > eventBuffer+2 {
+		t.Errorf("subscriber got %d events, events over buffer should be dropped", received)
+	}
+	select {
+	case event := <-other:
+		t.Errorf("subscriber of video 2 got %+v", event)
+	default:
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("event was sent after unsubscribe")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel wasn't closed after unsubscribe")
+	}
+}
+
+func TestEventHubThrottlesProgress(t *testing.T) {
+	h := newEventHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := h.subscribe(ctx, 0, time.Second)
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		//one progress event every 100ms for 3 seconds
+		at := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		h.publish(Event{Type: ProgressEvent, VideoId: 1, At: at, Video: &Video{Id: 1, Status: Processing}})
+	}
+	received := 0
+	for {
+		select {
+		case <-events:
+			received++
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if received != 3 {
+		t.Errorf("subscriber got %d progress events in 3 seconds with interval 1s, want 3", received)
+	}
+}
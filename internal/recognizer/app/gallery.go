@@ -0,0 +1,518 @@
+package recognizer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+var (
+	ErrPersonNotFound   = errors.New("person not found")
+	ErrPersonExists     = errors.New("person already exists")
+	ErrInvalidPerson    = errors.New("invalid person name")
+	ErrPhotoNotFound    = errors.New("photo not found")
+	ErrPhotoExists      = errors.New("photo already exists")
+	ErrUnreadableImage  = errors.New("unable to decode image")
+	ErrNoFace           = errors.New("no face is detected")
+	ErrFaceNotSelected  = errors.New("face is not selected")
+	ErrDetectorFailed   = errors.New("unable to detect faces")
+	ErrRecognizerFailed = errors.New("unable to recognize face")
+)
+
+// GallerySnapshot is an immutable state of gallery, jobs keep it while they process frame
+type GallerySnapshot struct {
+	//it is increased on every change of gallery
+	Version int64
+	Persons []Person
+	//index of descriptors of this version, it mustn't be changed
+	Matcher Matcher
+	//thresholds of persons by metric, which override thresholds of job
+	Thresholds map[string]MetricThresholds
+	//mean descriptors of persons with photos
+	Centroids map[string]face.Descriptor
+	//problems of files, which weren't enrolled into this version
+	Report EnrollmentReport
+}
+
+// PersonInfo describes person of gallery
+type PersonInfo struct {
+	Name   string      `json:"name"`
+	Photos []PhotoInfo `json:"photos"`
+	//thresholds of recognition of this person by metric, they are kept in thresholds.json in folder of person
+	Thresholds MetricThresholds `json:"thresholds,omitempty"`
+}
+
+// PhotoInfo is a photo of person with descriptor of face on it
+type PhotoInfo struct {
+	Name       string          `json:"name"`
+	Descriptor face.Descriptor `json:"descriptor"`
+}
+
+// Gallery keeps known persons. Every person is a folder in personsPath with photos of its face, so gallery is the same
+// after restart. Every change publishes new snapshot, running jobs use it from the next frame
+type Gallery struct {
+	path string
+	//serializes changes of folders and of persons
+	mu sync.Mutex
+	//descriptors of photos by person name and file name
+	persons map[string]map[string]face.Descriptor
+	//problems of skipped files by person name, problems of the whole gallery are kept under empty name
+	problems map[string][]EnrollmentProblem
+	//thresholds of persons, which have them
+	thresholds map[string]MetricThresholds
+	//index of descriptors of persons, it is changed incrementally and cloned into every snapshot
+	index Matcher
+	//file, where index is saved between restarts
+	indexPath string
+	snapshot  atomic.Pointer[GallerySnapshot]
+}
+
+func newGallery(path, indexPath string, index Matcher) *Gallery {
+	g := &Gallery{
+		path:       path,
+		persons:    make(map[string]map[string]face.Descriptor),
+		problems:   make(map[string][]EnrollmentProblem),
+		thresholds: make(map[string]MetricThresholds),
+		index:      index,
+		indexPath:  indexPath}
+	g.snapshot.Store(&GallerySnapshot{Matcher: index.Clone()})
+	return g
+}
+
+// id of descriptor of photo in index
+func photoId(name, photo string) string {
+	return name + "/" + photo
+}
+
+// replaces photos of person in persons and in index, nil photos remove person. It must be called with mu held
+func (g *Gallery) setPhotos(name string, photos map[string]face.Descriptor) {
+	for photo := range g.persons[name] {
+		g.index.Delete(photoId(name, photo))
+	}
+	if photos == nil {
+		delete(g.persons, name)
+		return
+	}
+	for photo, descriptor := range photos {
+		g.index.Insert(photoId(name, photo), name, descriptor)
+	}
+	g.persons[name] = photos
+}
+
+// Snapshot returns current state of gallery
+func (g *Gallery) Snapshot() *GallerySnapshot {
+	return g.snapshot.Load()
+}
+
+// replace sets persons loaded from folders together with problems of files, which were skipped. Index is restored
+// from indexPath, if it was saved for the same persons, otherwise it is built from scratch
+func (g *Gallery) replace(persons map[string]map[string]face.Descriptor, problems []EnrollmentProblem) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.loadIndex(persons); err == nil {
+		g.persons = persons
+	} else {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("gallery index is rebuilt: %s", err.Error())
+		}
+		for name := range g.persons {
+			g.setPhotos(name, nil)
+		}
+		for name, photos := range persons {
+			g.setPhotos(name, photos)
+		}
+	}
+	g.problems = make(map[string][]EnrollmentProblem)
+	for _, problem := range problems {
+		g.problems[problem.Person] = append(g.problems[problem.Person], problem)
+	}
+	g.thresholds = make(map[string]MetricThresholds)
+	for name := range persons {
+		g.readThresholds(name)
+	}
+	g.publish()
+}
+
+// loadedPerson is a person rebuilt from its folder
+type loadedPerson struct {
+	photos   map[string]face.Descriptor
+	problems []EnrollmentProblem
+}
+
+// apply replaces given persons with rebuilt ones, nil person is removed. Every change is published in one snapshot,
+// so jobs never see half of update
+func (g *Gallery) apply(updates map[string]*loadedPerson) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name, person := range updates {
+		if person == nil {
+			g.setPhotos(name, nil)
+			delete(g.problems, name)
+			delete(g.thresholds, name)
+			continue
+		}
+		g.setPhotos(name, person.photos)
+		g.problems[name] = person.problems
+		g.readThresholds(name)
+	}
+	g.publish()
+}
+
+// reads thresholds of person from its folder, unreadable thresholds are reported as problem of person.
+// It must be called with mu held
+func (g *Gallery) readThresholds(name string) {
+	delete(g.thresholds, name)
+	t, err := readThresholds(g.path, name)
+	if err != nil {
+		g.problems[name] = append(g.problems[name], newProblem(name, thresholdsFile, err))
+		return
+	}
+	if !t.empty() {
+		g.thresholds[name] = t
+	}
+}
+
+// builds new snapshot from persons, it must be called with mu held
+func (g *Gallery) publish() {
+	names := make([]string, 0, len(g.persons))
+	for name := range g.persons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	version := g.Snapshot().Version + 1
+	snapshot := &GallerySnapshot{
+		Version:    version,
+		Persons:    make([]Person, 0, len(names)),
+		Matcher:    g.index.Clone(),
+		Thresholds: make(map[string]MetricThresholds, len(g.thresholds)),
+		Report: EnrollmentReport{
+			Version:   version,
+			CreatedAt: time.Now(),
+			Persons:   len(names),
+			Problems:  []EnrollmentProblem{}}}
+	for _, name := range names {
+		person := Person{Name: name}
+		for _, photo := range sortedPhotos(g.persons[name]) {
+			person.Descriptors = append(person.Descriptors, photo.Descriptor)
+		}
+		snapshot.Persons = append(snapshot.Persons, person)
+		snapshot.Report.Photos += len(person.Descriptors)
+	}
+	for name, t := range g.thresholds {
+		snapshot.Thresholds[name] = t
+	}
+	snapshot.Centroids = centroids(snapshot.Persons)
+	for _, problems := range g.problems {
+		snapshot.Report.Problems = append(snapshot.Report.Problems, problems...)
+	}
+	sort.Slice(snapshot.Report.Problems, func(i, j int) bool {
+		a, b := snapshot.Report.Problems[i], snapshot.Report.Problems[j]
+		if a.Person != b.Person {
+			return a.Person < b.Person
+		}
+		return a.Photo < b.Photo
+	})
+	g.snapshot.Store(snapshot)
+}
+
+func sortedPhotos(photos map[string]face.Descriptor) []PhotoInfo {
+	result := make([]PhotoInfo, 0, len(photos))
+	for name, descriptor := range photos {
+		result = append(result, PhotoInfo{Name: name, Descriptor: descriptor})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// name of person is a name of folder, so it must be a single path element. Unknown is reserved for unknown faces
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || name == Unknown {
+		return fmt.Errorf("%w: %q", ErrInvalidPerson, name)
+	}
+	return nil
+}
+
+// returns description of person, it must be called with mu held
+func (g *Gallery) info(name string) PersonInfo {
+	return PersonInfo{Name: name, Photos: sortedPhotos(g.persons[name]), Thresholds: g.thresholds[name]}
+}
+
+func (g *Gallery) list() []PersonInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := make([]PersonInfo, 0, len(g.persons))
+	for name := range g.persons {
+		result = append(result, g.info(name))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (g *Gallery) get(name string) (PersonInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.persons[name]; !ok {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	return g.info(name), nil
+}
+
+func (g *Gallery) create(name string) (PersonInfo, error) {
+	if err := checkName(name); err != nil {
+		return PersonInfo{}, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.persons[name]; ok {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonExists, name)
+	}
+	if err := os.Mkdir(path.Join(g.path, name), 0750); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonExists, name)
+		}
+		return PersonInfo{}, err
+	}
+	g.persons[name] = make(map[string]face.Descriptor)
+	g.publish()
+	return PersonInfo{Name: name, Photos: []PhotoInfo{}}, nil
+}
+
+func (g *Gallery) rename(name, newName string) (PersonInfo, error) {
+	if err := checkName(newName); err != nil {
+		return PersonInfo{}, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	photos, ok := g.persons[name]
+	if !ok {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	if name == newName {
+		return g.info(name), nil
+	}
+	if _, ok := g.persons[newName]; ok {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonExists, newName)
+	}
+	if _, err := os.Stat(path.Join(g.path, newName)); err == nil {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonExists, newName)
+	}
+	if err := os.Rename(path.Join(g.path, name), path.Join(g.path, newName)); err != nil {
+		return PersonInfo{}, err
+	}
+	g.setPhotos(name, nil)
+	g.setPhotos(newName, photos)
+	if problems, ok := g.problems[name]; ok {
+		for i := range problems {
+			problems[i].Person = newName
+		}
+		delete(g.problems, name)
+		g.problems[newName] = problems
+	}
+	if t, ok := g.thresholds[name]; ok {
+		delete(g.thresholds, name)
+		g.thresholds[newName] = t
+	}
+	g.publish()
+	return g.info(newName), nil
+}
+
+func (g *Gallery) delete(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.persons[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	if err := os.RemoveAll(path.Join(g.path, name)); err != nil {
+		return err
+	}
+	g.setPhotos(name, nil)
+	delete(g.problems, name)
+	delete(g.thresholds, name)
+	g.publish()
+	return nil
+}
+
+// setThresholds saves thresholds of person in its folder
+func (g *Gallery) setThresholds(name string, t MetricThresholds) (PersonInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.persons[name]; !ok {
+		return PersonInfo{}, fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	if err := writeThresholds(g.path, name, t); err != nil {
+		return PersonInfo{}, err
+	}
+	delete(g.thresholds, name)
+	if !t.empty() {
+		g.thresholds[name] = t
+	}
+	//problem of previous thresholds file is fixed by new file
+	problems := g.problems[name][:0]
+	for _, problem := range g.problems[name] {
+		if problem.Photo != thresholdsFile {
+			problems = append(problems, problem)
+		}
+	}
+	g.problems[name] = problems
+	g.publish()
+	return g.info(name), nil
+}
+
+// addPhoto saves photo with already computed descriptor in folder of person
+func (g *Gallery) addPhoto(name, photo string, data []byte, descriptor face.Descriptor) (PhotoInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	photos, ok := g.persons[name]
+	if !ok {
+		return PhotoInfo{}, fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	if _, ok := photos[photo]; ok {
+		return PhotoInfo{}, fmt.Errorf("%w: %s", ErrPhotoExists, photo)
+	}
+	if err := os.WriteFile(path.Join(g.path, name, photo), data, 0640); err != nil {
+		return PhotoInfo{}, err
+	}
+	photos[photo] = descriptor
+	g.index.Insert(photoId(name, photo), name, descriptor)
+	g.publish()
+	return PhotoInfo{Name: photo, Descriptor: descriptor}, nil
+}
+
+func (g *Gallery) removePhoto(name, photo string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	photos, ok := g.persons[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, name)
+	}
+	if _, ok := photos[photo]; !ok {
+		return fmt.Errorf("%w: %s", ErrPhotoNotFound, photo)
+	}
+	if err := os.Remove(path.Join(g.path, name, photo)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(photos, photo)
+	g.index.Delete(photoId(name, photo))
+	g.publish()
+	return nil
+}
+
+// ListPersons returns every person of gallery with descriptors of its photos
+func (vP *VideoProcessor) ListPersons() []PersonInfo {
+	return vP.gallery.list()
+}
+
+// GetPerson returns person with given name
+func (vP *VideoProcessor) GetPerson(name string) (PersonInfo, error) {
+	return vP.gallery.get(name)
+}
+
+// CreatePerson adds person without photos to gallery
+func (vP *VideoProcessor) CreatePerson(name string) (PersonInfo, error) {
+	return vP.gallery.create(name)
+}
+
+// RenamePerson changes name of person, matches found on videos before renaming keep the old name
+func (vP *VideoProcessor) RenamePerson(name, newName string) (PersonInfo, error) {
+	return vP.gallery.rename(name, newName)
+}
+
+// DeletePerson removes person with all its photos
+func (vP *VideoProcessor) DeletePerson(name string) error {
+	return vP.gallery.delete(name)
+}
+
+// AddPhoto enrolls photo of person: faces on photo are detected with models from pool, face chosen by selector is
+// vectorized and photo is saved in folder of person. If chosen face isn't the one chosen by default, only region around
+// this face is saved, so the same face is enrolled again, when gallery is loaded from folders. Photo is named by hash of
+// its content, so the same photo can't be added twice. Detected faces are returned even if no face was chosen
+func (vP *VideoProcessor) AddPhoto(ctx context.Context, name, fileName string, data []byte, selector FaceSelector) (Enrollment, error) {
+	if _, err := vP.gallery.get(name); err != nil {
+		return Enrollment{}, err
+	}
+	m, err := vP.models.Acquire(ctx)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	defer vP.models.Release(m)
+
+	img, detects, err := detectPhoto(m, data)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	defer img.Close()
+	selected, err := selectFace(detects, selector)
+	if err != nil {
+		return Enrollment{Faces: detectedFaces(detects, -1)}, err
+	}
+	enrollment := Enrollment{Faces: detectedFaces(detects, selected)}
+	descriptor, err := m.recognize(img, detects[selected].Rectangle)
+	if err != nil {
+		return enrollment, fmt.Errorf("%w: %w", ErrRecognizerFailed, err)
+	}
+	if byDefault, _ := selectFace(detects, FaceSelector{}); selected != byDefault {
+		if data, fileName, err = cropFace(img, detects[selected].Rectangle, fileName); err != nil {
+			return enrollment, err
+		}
+	}
+
+	vP.descriptors.put(vP.descriptors.key(data), descriptor)
+	if err := vP.descriptors.save(); err != nil {
+		return enrollment, fmt.Errorf("unable to save descriptor cache: %w", err)
+	}
+	enrollment.Photo, err = vP.gallery.addPhoto(name, photoName(fileName, data), data, descriptor)
+	return enrollment, err
+}
+
+// encodes region around face, it is big enough for face to stay the largest one on it. Returns encoded region with
+// name of file, which has matching extension
+func cropFace(img gocv.Mat, rect image.Rectangle, fileName string) ([]byte, string, error) {
+	margin := rect.Size().Div(2)
+	region := image.Rectangle{Min: rect.Min.Sub(margin), Max: rect.Max.Add(margin)}.
+		Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	crop := img.Region(region)
+	defer crop.Close()
+
+	ext := gocv.JPEGFileExt
+	if strings.EqualFold(filepath.Ext(fileName), string(gocv.PNGFileExt)) {
+		ext = gocv.PNGFileExt
+	}
+	buf, err := gocv.IMEncode(ext, crop)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to encode face region: %w", err)
+	}
+	defer buf.Close()
+	return bytes.Clone(buf.GetBytes()), "face" + string(ext), nil
+}
+
+// RemovePhoto removes photo from folder of person
+func (vP *VideoProcessor) RemovePhoto(name, photo string) error {
+	return vP.gallery.removePhoto(name, photo)
+}
+
+// returns name of photo file made from hash of its content and extension of uploaded file
+func photoName(fileName string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == "" || strings.ContainsAny(ext, `/\`) {
+		ext = ".jpg"
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]) + ext
+}
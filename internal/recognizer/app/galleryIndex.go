@@ -0,0 +1,109 @@
+package recognizer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// Версия формата файла индекса галереи.
+const galleryIndexVersion = 1
+
+var galleryIndexMagic = [4]byte{'G', 'C', 'V', 'I'}
+
+// file is a header followed by saved matcher. Digest identifies persons, which were indexed, so index saved for
+// other photos or by other models isn't loaded
+type galleryIndexHeader struct {
+	Magic   [4]byte
+	Version uint32
+	Digest  [sha256.Size]byte
+}
+
+// returns sha256 of every person, photo and descriptor in sorted order
+func galleryDigest(persons map[string]map[string]face.Descriptor) [sha256.Size]byte {
+	names := make([]string, 0, len(persons))
+	for name := range persons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		for _, photo := range sortedPhotos(persons[name]) {
+			_ = writeString(h, name)
+			_ = writeString(h, photo.Name)
+			_ = binary.Write(h, binary.LittleEndian, photo.Descriptor)
+		}
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+// loadIndex replaces index by the one saved in indexPath, if it was saved for given persons. It must be called with mu held
+func (g *Gallery) loadIndex(persons map[string]map[string]face.Descriptor) error {
+	file, err := os.Open(g.indexPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var header galleryIndexHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("unable to read gallery index header: %w", err)
+	}
+	if header.Magic != galleryIndexMagic {
+		return fmt.Errorf("%s is not a gallery index", g.indexPath)
+	}
+	if header.Version != galleryIndexVersion {
+		return fmt.Errorf("gallery index has version %d, expected %d", header.Version, galleryIndexVersion)
+	}
+	if header.Digest != galleryDigest(persons) {
+		return errors.New("gallery index was saved for other photos")
+	}
+	index := g.index.Clone()
+	if err := index.Load(r); err != nil {
+		return err
+	}
+	g.index = index
+	return nil
+}
+
+// saveIndex writes index to indexPath, so it isn't rebuilt on the next start
+func (g *Gallery) saveIndex() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(g.indexPath), 0750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(g.indexPath), filepath.Base(g.indexPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := g.writeIndex(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), g.indexPath)
+}
+
+func (g *Gallery) writeIndex(w io.Writer) error {
+	header := galleryIndexHeader{Magic: galleryIndexMagic, Version: galleryIndexVersion, Digest: galleryDigest(g.persons)}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return g.index.Save(w)
+}
@@ -0,0 +1,119 @@
+package recognizer
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// time, during which changes of gallery folders are collected before affected persons are rebuilt,
+// copying of a folder with photos produces lots of events
+const galleryReloadDelay = 500 * time.Millisecond
+
+// watchGallery watches folder of persons and folders of every person, persons with changed folders are rebuilt and
+// published in new gallery snapshot. Watching stops on shutdown
+func (vP *VideoProcessor) watchGallery(personsPath string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(personsPath); err != nil {
+		watcher.Close()
+		return err
+	}
+	dirs, err := os.ReadDir(personsPath)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			if err := watcher.Add(path.Join(personsPath, dir.Name())); err != nil {
+				log.Printf("unable to watch person %s: %s", dir.Name(), err.Error())
+			}
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		changed := make(map[string]struct{})
+		timer := time.NewTimer(galleryReloadDelay)
+		timer.Stop()
+		for {
+			select {
+			case <-vP.ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if name := changedPerson(personsPath, event.Name); name != "" {
+					changed[name] = struct{}{}
+					timer.Reset(galleryReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("gallery watcher: %s", err.Error())
+			case <-timer.C:
+				vP.reloadPersons(watcher, personsPath, changed)
+				changed = make(map[string]struct{})
+			}
+		}
+	}()
+	return nil
+}
+
+// returns name of person, whose folder contains changed file, or empty string if file doesn't belong to any person
+func changedPerson(personsPath, file string) string {
+	rel, err := filepath.Rel(personsPath, file)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	name := strings.Split(filepath.ToSlash(rel), "/")[0]
+	if checkName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// rebuilds descriptors of given persons and swaps them into gallery at once, persons without folder are removed
+func (vP *VideoProcessor) reloadPersons(watcher *fsnotify.Watcher, personsPath string, names map[string]struct{}) {
+	m, err := vP.models.Acquire(vP.ctx)
+	if err != nil {
+		log.Printf("unable to reload persons: %s", err.Error())
+		return
+	}
+	defer vP.models.Release(m)
+
+	updates := make(map[string]*loadedPerson, len(names))
+	for name := range names {
+		dir := path.Join(personsPath, name)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			//watch of renamed folder keeps working under old name, so it is removed explicitly
+			_ = watcher.Remove(dir)
+			updates[name] = nil
+			continue
+		}
+		//watch is added for new folders, it does nothing for already watched ones
+		if err := watcher.Add(dir); err != nil {
+			log.Printf("unable to watch person %s: %s", name, err.Error())
+		}
+		photos, problems := loadPerson(m, vP.descriptors, personsPath, name)
+		updates[name] = &loadedPerson{photos: photos, problems: problems}
+	}
+	vP.gallery.apply(updates)
+	if err := vP.gallery.saveIndex(); err != nil {
+		log.Printf("unable to save gallery index: %s", err.Error())
+	}
+	if err := vP.descriptors.save(); err != nil {
+		log.Printf("unable to save descriptor cache: %s", err.Error())
+	}
+	log.Printf("%d persons were reloaded, gallery version is %d", len(updates), vP.gallery.Snapshot().Version)
+}
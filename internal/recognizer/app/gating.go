@@ -0,0 +1,151 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Ways to compare frame with the last analyzed one
+const (
+	//Bhattacharyya distance between histograms of brightness, it detects shot boundaries and changes of light
+	GatingHistogram = "histogram"
+	//share of pixels, whose brightness changed, it detects motion
+	GatingDifference = "difference"
+)
+
+// Пороги по умолчанию: расстояние между гистограммами и доля изменившихся пикселей.
+const (
+	defaultHistogramThreshold  = 0.1
+	defaultDifferenceThreshold = 0.01
+)
+
+// Параметры сравнения кадров: ширина уменьшенного кадра, число столбцов гистограммы и изменение яркости пикселя,
+// которое считается движением, а не шумом.
+const (
+	gatingWidth      = 160
+	histogramBins    = 64
+	pixelDifference  = 25
+	gatingBlurKernel = 5
+)
+
+var ErrInvalidGating = errors.New("invalid gating")
+
+// Gating runs detection only on frames, which differ enough from the last analyzed frame. Other frames get detections
+// of the last analyzed frame. Zero Gating runs detection on every sampled frame
+type Gating struct {
+	//one of Gating* constants, empty means no gating
+	Mode string `json:"mode,omitempty"`
+	//score of frame, which starts detection, 0 means default threshold of mode
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// Validate checks mode and threshold, both scores are in [0, 1]
+func (g Gating) Validate() error {
+	switch g.Mode {
+	case "", GatingHistogram, GatingDifference:
+	default:
+		return fmt.Errorf("%w: mode should be %s or %s", ErrInvalidGating, GatingHistogram, GatingDifference)
+	}
+	if g.Threshold < 0 || g.Threshold > 1 {
+		return fmt.Errorf("%w: threshold should be in [0, 1]", ErrInvalidGating)
+	}
+	if g.Threshold > 0 && g.Mode == "" {
+		return fmt.Errorf("%w: threshold requires mode", ErrInvalidGating)
+	}
+	return nil
+}
+
+// gate keeps reduced copy of the last analyzed frame. It should be closed to free memory of Mats
+type gate struct {
+	mode      string
+	threshold float64
+	//reduced copy of the last analyzed frame, it is empty until the first frame
+	reference gocv.Mat
+	//reduced copy of current frame: blurred gray image or histogram of brightness
+	current gocv.Mat
+	gray    gocv.Mat
+	small   gocv.Mat
+	diff    gocv.Mat
+	mask    gocv.Mat
+}
+
+// returns nil for zero Gating, nil gate lets every frame through
+func newGate(g Gating) *gate {
+	if g.Mode == "" {
+		return nil
+	}
+	threshold := g.Threshold
+	if threshold == 0 {
+		threshold = defaultHistogramThreshold
+		if g.Mode == GatingDifference {
+			threshold = defaultDifferenceThreshold
+		}
+	}
+	return &gate{mode: g.Mode, threshold: threshold, reference: gocv.NewMat(), current: gocv.NewMat(),
+		gray: gocv.NewMat(), small: gocv.NewMat(), diff: gocv.NewMat(), mask: gocv.NewMat()}
+}
+
+// changed returns whether frame should be analyzed. Analyzed frame becomes reference, so slow changes are summed up
+// until they cross threshold
+func (g *gate) changed(img gocv.Mat) bool {
+	if g == nil {
+		return true
+	}
+	g.reduce(img)
+	if g.reference.Empty() {
+		g.current.CopyTo(&g.reference)
+		return true
+	}
+	if g.score() < g.threshold {
+		return false
+	}
+	g.current.CopyTo(&g.reference)
+	return true
+}
+
+// converts frame to small blurred gray image or to its histogram in current
+func (g *gate) reduce(img gocv.Mat) {
+	gocv.CvtColor(img, &g.gray, gocv.ColorBGRToGray)
+	height := max(1, g.gray.Rows()*gatingWidth/max(1, g.gray.Cols()))
+	gocv.Resize(g.gray, &g.small, image.Pt(gatingWidth, height), 0, 0, gocv.InterpolationArea)
+	if g.mode == GatingHistogram {
+		gocv.CalcHist([]gocv.Mat{g.small}, []int{0}, g.mask, &g.current, []int{histogramBins}, []float64{0, 256}, false)
+		return
+	}
+	gocv.GaussianBlur(g.small, &g.current, image.Pt(gatingBlurKernel, gatingBlurKernel), 0, 0, gocv.BorderDefault)
+}
+
+// returns difference between current and reference frames in [0, 1]
+func (g *gate) score() float64 {
+	if g.mode == GatingHistogram {
+		return float64(gocv.CompareHist(g.reference, g.current, gocv.HistCmpBhattacharya))
+	}
+	gocv.AbsDiff(g.reference, g.current, &g.diff)
+	gocv.Threshold(g.diff, &g.diff, pixelDifference, 255, gocv.ThresholdBinary)
+	return float64(gocv.CountNonZero(g.diff)) / float64(max(1, g.diff.Total()))
+}
+
+func (g *gate) Close() {
+	if g == nil {
+		return
+	}
+	g.reference.Close()
+	g.current.Close()
+	g.gray.Close()
+	g.small.Close()
+	g.diff.Close()
+	g.mask.Close()
+}
+
+// carry returns detections of the last analyzed frame moved to given frame
+func carry(detections []FrameDetection, frameIndex int64, timestamp float64) []FrameDetection {
+	result := make([]FrameDetection, len(detections))
+	for i, detection := range detections {
+		detection.Frame, detection.TimestampMs, detection.Carried = frameIndex, timestamp, true
+		result[i] = detection
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package recognizer
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// returns gray frame 320x240 of given brightness with square of brightness 255 at given point, zero size means no square
+func syntheticFrame(t *testing.T, brightness float64, at image.Point, size int) gocv.Mat {
+	img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(brightness, brightness, brightness, 0), 240, 320, gocv.MatTypeCV8UC3)
+	t.Cleanup(func() { img.Close() })
+	if size > 0 {
+		gocv.Rectangle(&img, image.Rectangle{Min: at, Max: at.Add(image.Pt(size, size))}, color.RGBA{255, 255, 255, 0}, -1)
+	}
+	return img
+}
+
+type gateStep struct {
+	name       string
+	brightness float64
+	at         image.Point
+	size       int
+	changed    bool
+}
+
+func TestGateThresholds(t *testing.T) {
+	tests := []struct {
+		name   string
+		gating Gating
+		steps  []gateStep
+	}{
+		{"no gating", Gating{}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"the same frame", 100, image.Point{}, 0, true},
+		}},
+		{"difference", Gating{Mode: GatingDifference}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"the same frame", 100, image.Point{}, 0, false},
+			//noise and small changes of light don't change brightness of pixels enough
+			{"dimmer light", 110, image.Point{}, 0, false},
+			{"small object", 100, image.Pt(100, 100), 10, false},
+			{"object enters", 100, image.Pt(100, 100), 60, true},
+			{"object stays", 100, image.Pt(100, 100), 60, false},
+			{"object moves", 100, image.Pt(160, 100), 60, true},
+			{"scene change", 20, image.Point{}, 0, true},
+		}},
+		//changes are compared with the last analyzed frame, so slow changes are summed up
+		{"slow changes", Gating{Mode: GatingDifference}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"lighter", 110, image.Point{}, 0, false},
+			{"more lighter", 120, image.Point{}, 0, false},
+			{"change crosses threshold", 130, image.Point{}, 0, true},
+			{"lighter than new reference", 140, image.Point{}, 0, false},
+		}},
+		{"low difference threshold", Gating{Mode: GatingDifference, Threshold: 0.001}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"small object", 100, image.Pt(100, 100), 10, true},
+		}},
+		{"histogram", Gating{Mode: GatingHistogram}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"the same frame", 100, image.Point{}, 0, false},
+			{"brightness within bin", 102, image.Point{}, 0, false},
+			{"small object", 102, image.Pt(100, 100), 10, false},
+			//motion doesn't change histogram of frame
+			{"small object moves", 102, image.Pt(200, 150), 10, false},
+			{"scene change", 200, image.Point{}, 0, true},
+			{"scene with big object", 200, image.Pt(40, 40), 160, true},
+		}},
+		{"high histogram threshold", Gating{Mode: GatingHistogram, Threshold: 1}, []gateStep{
+			{"first frame", 100, image.Point{}, 0, true},
+			{"big object", 100, image.Pt(40, 40), 160, false},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newGate(test.gating)
+			defer g.Close()
+			for _, step := range test.steps {
+				img := syntheticFrame(t, step.brightness, step.at, step.size)
+				if changed := g.changed(img); changed != step.changed {
+					t.Errorf("%s: changed = %v, want %v", step.name, changed, step.changed)
+				}
+			}
+		})
+	}
+}
+
+func TestGateDefaultThresholds(t *testing.T) {
+	tests := []struct {
+		gating Gating
+		want   float64
+	}{
+		{Gating{Mode: GatingHistogram}, defaultHistogramThreshold},
+		{Gating{Mode: GatingDifference}, defaultDifferenceThreshold},
+		{Gating{Mode: GatingDifference, Threshold: 0.2}, 0.2},
+	}
+	for _, test := range tests {
+		g := newGate(test.gating)
+		if g.threshold != test.want {
+			t.Errorf("%+v has threshold %g, want %g", test.gating, g.threshold, test.want)
+		}
+		g.Close()
+	}
+	if g := newGate(Gating{}); g != nil {
+		t.Error("zero Gating returned gate")
+	}
+}
+
+func TestGatingValidate(t *testing.T) {
+	tests := []struct {
+		gating Gating
+		valid  bool
+	}{
+		{Gating{}, true},
+		{Gating{Mode: GatingHistogram}, true},
+		{Gating{Mode: GatingDifference, Threshold: 0.05}, true},
+		{Gating{Mode: GatingHistogram, Threshold: 1}, true},
+		{Gating{Mode: "motion"}, false},
+		{Gating{Mode: GatingDifference, Threshold: -0.1}, false},
+		{Gating{Mode: GatingHistogram, Threshold: 1.5}, false},
+		{Gating{Threshold: 0.5}, false},
+	}
+	for _, test := range tests {
+		err := test.gating.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%+v was rejected: %v", test.gating, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidGating) {
+			t.Errorf("%+v: Validate returned %v, want ErrInvalidGating", test.gating, err)
+		}
+	}
+}
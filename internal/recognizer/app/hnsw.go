@@ -0,0 +1,403 @@
+package recognizer
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// HNSWConfig are parameters of HNSW graph
+type HNSWConfig struct {
+	//max amount of neighbours of node on upper levels, nodes of level 0 have twice more
+	M int
+	//size of candidate list during insert, bigger value gives better graph and slower inserts
+	EfConstruction int
+	//size of candidate list during search, bigger value gives better recall and slower search
+	EfSearch int
+}
+
+var DefaultHNSWConfig = HNSWConfig{M: 16, EfConstruction: 200, EfSearch: 128}
+
+type hnswNode struct {
+	id    string
+	entry matcherEntry
+	//neighbours of node on every level from 0 to level of node
+	neighbors [][]int32
+	//deleted node is kept in graph for navigation until graph is rebuilt
+	deleted bool
+}
+
+// HNSWMatcher is an approximate nearest neighbours index: descriptors are nodes of layered proximity graph, search goes
+// from sparse upper levels down to level 0 visiting only small part of nodes. Deleted nodes are marked and skipped,
+// graph is rebuilt from alive nodes when half of nodes are deleted
+type HNSWMatcher struct {
+	config HNSWConfig
+	//normalization factor of level generation
+	levelMult float64
+	nodes     []hnswNode
+	//alive nodes by id
+	ids      map[string]int32
+	entry    int32
+	maxLevel int
+	rng      *rand.Rand
+}
+
+func NewHNSWMatcher(config HNSWConfig) *HNSWMatcher {
+	if config.M < 2 {
+		config.M = 2
+	}
+	if config.EfConstruction < config.M {
+		config.EfConstruction = config.M
+	}
+	if config.EfSearch < 1 {
+		config.EfSearch = 1
+	}
+	return &HNSWMatcher{
+		config:    config,
+		levelMult: 1 / math.Log(float64(config.M)),
+		ids:       make(map[string]int32),
+		entry:     -1,
+		rng:       rand.New(rand.NewSource(1))}
+}
+
+func (m *HNSWMatcher) Len() int {
+	return len(m.ids)
+}
+
+func (m *HNSWMatcher) maxNeighbors(level int) int {
+	if level == 0 {
+		return 2 * m.config.M
+	}
+	return m.config.M
+}
+
+func (m *HNSWMatcher) distance(descriptor face.Descriptor, node int32) float64 {
+	return euclidianDistance(descriptor, m.nodes[node].entry.descriptor)
+}
+
+func (m *HNSWMatcher) Insert(id, person string, descriptor face.Descriptor) {
+	m.Delete(id)
+	level := int(-math.Log(1-m.rng.Float64()) * m.levelMult)
+	node := int32(len(m.nodes))
+	m.nodes = append(m.nodes, hnswNode{
+		id:        id,
+		entry:     matcherEntry{person: person, descriptor: descriptor},
+		neighbors: make([][]int32, level+1)})
+	m.ids[id] = node
+	if m.entry < 0 {
+		m.entry, m.maxLevel = node, level
+		return
+	}
+
+	current := m.entry
+	for l := m.maxLevel; l > level; l-- {
+		current = m.greedy(descriptor, current, l)
+	}
+	for l := min(level, m.maxLevel); l >= 0; l-- {
+		candidates := m.searchLevel(descriptor, current, m.config.EfConstruction, l, true)
+		neighbors := candidates
+		if len(neighbors) > m.config.M {
+			neighbors = neighbors[:m.config.M]
+		}
+		for _, neighbor := range neighbors {
+			m.nodes[node].neighbors[l] = append(m.nodes[node].neighbors[l], neighbor.node)
+			m.connect(neighbor.node, node, l)
+		}
+		current = candidates[0].node
+	}
+	if level > m.maxLevel {
+		m.entry, m.maxLevel = node, level
+	}
+}
+
+// adds link from node to neighbor, the farthest neighbours are dropped when node has too many of them
+func (m *HNSWMatcher) connect(node, neighbor int32, level int) {
+	links := append(m.nodes[node].neighbors[level], neighbor)
+	if limit := m.maxNeighbors(level); len(links) > limit {
+		descriptor := m.nodes[node].entry.descriptor
+		candidates := make([]candidate, len(links))
+		for i, link := range links {
+			candidates[i] = candidate{node: link, distance: m.distance(descriptor, link)}
+		}
+		sortCandidates(candidates)
+		links = links[:0]
+		for _, c := range candidates[:limit] {
+			links = append(links, c.node)
+		}
+	}
+	m.nodes[node].neighbors[level] = links
+}
+
+func (m *HNSWMatcher) Delete(id string) {
+	node, ok := m.ids[id]
+	if !ok {
+		return
+	}
+	m.nodes[node].deleted = true
+	delete(m.ids, id)
+	if len(m.ids) == 0 {
+		m.nodes, m.entry, m.maxLevel = nil, -1, 0
+		return
+	}
+	if len(m.nodes) > 2*len(m.ids) {
+		m.rebuild()
+	}
+}
+
+// inserts alive nodes into new graph
+func (m *HNSWMatcher) rebuild() {
+	nodes := m.nodes
+	m.nodes, m.ids, m.entry, m.maxLevel = nil, make(map[string]int32, len(m.ids)), -1, 0
+	for _, node := range nodes {
+		if !node.deleted {
+			m.Insert(node.id, node.entry.person, node.entry.descriptor)
+		}
+	}
+}
+
+func (m *HNSWMatcher) Search(descriptor face.Descriptor, k int) []Match {
+	if len(m.ids) == 0 || k <= 0 {
+		return nil
+	}
+	current := m.entry
+	for l := m.maxLevel; l > 0; l-- {
+		current = m.greedy(descriptor, current, l)
+	}
+	//one person may have several close descriptors, so more candidates are needed to find k different persons
+	ef := max(m.config.EfSearch, 4*k)
+	distances := make(map[string]float64)
+	for _, c := range m.searchLevel(descriptor, current, ef, 0, false) {
+		node := &m.nodes[c.node]
+		if best, ok := distances[node.entry.person]; !ok || c.distance < best {
+			distances[node.entry.person] = c.distance
+		}
+	}
+	return topMatches(distances, k)
+}
+
+// moves from node to its closest neighbour, while it is closer to descriptor
+func (m *HNSWMatcher) greedy(descriptor face.Descriptor, node int32, level int) int32 {
+	distance := m.distance(descriptor, node)
+	for changed := true; changed; {
+		changed = false
+		for _, neighbor := range m.nodes[node].neighbors[level] {
+			if d := m.distance(descriptor, neighbor); d < distance {
+				node, distance, changed = neighbor, d, true
+			}
+		}
+	}
+	return node
+}
+
+// returns up to ef nodes of level closest to descriptor ordered by distance. Deleted nodes are always used for
+// navigation, but they are returned only if withDeleted is set, otherwise they would take places of alive nodes in
+// results and lower recall after many deletes
+func (m *HNSWMatcher) searchLevel(descriptor face.Descriptor, entry int32, ef, level int, withDeleted bool) []candidate {
+	start := candidate{node: entry, distance: m.distance(descriptor, entry)}
+	visited := map[int32]struct{}{entry: {}}
+	candidates := &nearestFirst{start}
+	results := &farthestFirst{}
+	if withDeleted || !m.nodes[entry].deleted {
+		heap.Push(results, start)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.distance > (*results)[0].distance {
+			break
+		}
+		for _, neighbor := range m.nodes[c.node].neighbors[level] {
+			if _, ok := visited[neighbor]; ok {
+				continue
+			}
+			visited[neighbor] = struct{}{}
+			d := m.distance(descriptor, neighbor)
+			if results.Len() < ef || d < (*results)[0].distance {
+				heap.Push(candidates, candidate{node: neighbor, distance: d})
+				if withDeleted || !m.nodes[neighbor].deleted {
+					heap.Push(results, candidate{node: neighbor, distance: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+	found := []candidate(*results)
+	sortCandidates(found)
+	return found
+}
+
+func (m *HNSWMatcher) Clone() Matcher {
+	clone := *m
+	clone.nodes = make([]hnswNode, len(m.nodes))
+	for i, node := range m.nodes {
+		clone.nodes[i] = node
+		clone.nodes[i].neighbors = make([][]int32, len(node.neighbors))
+		for l, links := range node.neighbors {
+			clone.nodes[i].neighbors[l] = append([]int32(nil), links...)
+		}
+	}
+	clone.ids = make(map[string]int32, len(m.ids))
+	for id, node := range m.ids {
+		clone.ids[id] = node
+	}
+	clone.rng = rand.New(rand.NewSource(m.rng.Int63()))
+	return &clone
+}
+
+var hnswMagic = [4]byte{'G', 'C', 'V', 'H'}
+
+// Версия формата сохранённого HNSWMatcher.
+const hnswVersion = 1
+
+// Save writes graph as it is, so loaded matcher doesn't need to compute distances again
+func (m *HNSWMatcher) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeMatcherHeader(bw, hnswMagic, hnswVersion, uint32(len(m.nodes))); err != nil {
+		return err
+	}
+	params := [5]int32{int32(m.config.M), int32(m.config.EfConstruction), int32(m.config.EfSearch), m.entry, int32(m.maxLevel)}
+	if err := binary.Write(bw, binary.LittleEndian, params); err != nil {
+		return err
+	}
+	for _, node := range m.nodes {
+		if err := writeEntry(bw, node.id, node.entry); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.LittleEndian, node.deleted); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.LittleEndian, uint32(len(node.neighbors))); err != nil {
+			return err
+		}
+		for _, links := range node.neighbors {
+			if err := binary.Write(bw, binary.LittleEndian, uint32(len(links))); err != nil {
+				return err
+			}
+			if err := binary.Write(bw, binary.LittleEndian, links); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (m *HNSWMatcher) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	count, err := readMatcherHeader(br, hnswMagic, hnswVersion)
+	if err != nil {
+		return err
+	}
+	var params [5]int32
+	if err := binary.Read(br, binary.LittleEndian, &params); err != nil {
+		return fmt.Errorf("unable to read hnsw parameters: %w", err)
+	}
+	loaded := NewHNSWMatcher(HNSWConfig{M: int(params[0]), EfConstruction: int(params[1]), EfSearch: int(params[2])})
+	loaded.entry, loaded.maxLevel = params[3], int(params[4])
+	//count of damaged file may be huge, so nodes are allocated as they are read
+	loaded.nodes = make([]hnswNode, 0, min(count, maxPreallocated))
+	for i := 0; i < int(count); i++ {
+		loaded.nodes = append(loaded.nodes, hnswNode{})
+		node := &loaded.nodes[i]
+		if node.id, node.entry, err = readEntry(br); err != nil {
+			return err
+		}
+		var levels uint32
+		if err := binary.Read(br, binary.LittleEndian, &node.deleted); err != nil {
+			return fmt.Errorf("unable to read hnsw node: %w", err)
+		}
+		if err := binary.Read(br, binary.LittleEndian, &levels); err != nil {
+			return fmt.Errorf("unable to read hnsw node: %w", err)
+		}
+		if levels == 0 || levels > 64 {
+			return fmt.Errorf("hnsw node %d has %d levels", i, levels)
+		}
+		node.neighbors = make([][]int32, levels)
+		for l := range node.neighbors {
+			var links uint32
+			if err := binary.Read(br, binary.LittleEndian, &links); err != nil {
+				return fmt.Errorf("unable to read hnsw node: %w", err)
+			}
+			if links > uint32(loaded.maxNeighbors(l)+1) {
+				return fmt.Errorf("hnsw node %d has %d neighbours", i, links)
+			}
+			node.neighbors[l] = make([]int32, links)
+			if err := binary.Read(br, binary.LittleEndian, node.neighbors[l]); err != nil {
+				return fmt.Errorf("unable to read hnsw node: %w", err)
+			}
+		}
+		if !node.deleted {
+			loaded.ids[node.id] = int32(i)
+		}
+	}
+	if err := loaded.validate(); err != nil {
+		return err
+	}
+	*m = *loaded
+	return nil
+}
+
+// checks that every link of loaded graph points to existing node, so damaged file doesn't crash search
+func (m *HNSWMatcher) validate() error {
+	if len(m.nodes) == 0 {
+		m.entry, m.maxLevel = -1, 0
+		return nil
+	}
+	if m.entry < 0 || int(m.entry) >= len(m.nodes) || len(m.nodes[m.entry].neighbors) != m.maxLevel+1 {
+		return fmt.Errorf("hnsw entry point %d is invalid", m.entry)
+	}
+	for i, node := range m.nodes {
+		for l, links := range node.neighbors {
+			for _, link := range links {
+				if link < 0 || int(link) >= len(m.nodes) || len(m.nodes[link].neighbors) <= l {
+					return fmt.Errorf("hnsw node %d has invalid link %d", i, link)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type candidate struct {
+	node     int32
+	distance float64
+}
+
+func sortCandidates(candidates []candidate) {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+}
+
+// nearestFirst is a min-heap of candidates by distance
+type nearestFirst []candidate
+
+func (h nearestFirst) Len() int           { return len(h) }
+func (h nearestFirst) Less(i, j int) bool { return h[i].distance < h[j].distance }
+func (h nearestFirst) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nearestFirst) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *nearestFirst) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// farthestFirst is a max-heap of candidates by distance
+type farthestFirst []candidate
+
+func (h farthestFirst) Len() int           { return len(h) }
+func (h farthestFirst) Less(i, j int) bool { return h[i].distance > h[j].distance }
+func (h farthestFirst) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *farthestFirst) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *farthestFirst) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,88 @@
+package recognizer
+
+import (
+	"context"
+	"sync"
+)
+
+// job is a handle of running worker goroutine, it is used for controlling video processing from outside
+type job struct {
+	//cancels context of worker
+	cancel context.CancelCauseFunc
+	mu     sync.Mutex
+	//nil while job is not paused, it is closed when job is resumed
+	resume chan struct{}
+	//wakes worker, which waits for slot, when job is paused, so video is moved to Paused at once
+	wake chan struct{}
+}
+
+func newJob(cancel context.CancelCauseFunc, paused bool) *job {
+	j := &job{cancel: cancel, wake: make(chan struct{}, 1)}
+	if paused {
+		j.pause()
+	}
+	return j
+}
+
+func (j *job) pause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.resume == nil {
+		j.resume = make(chan struct{})
+	}
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (j *job) unpause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.resume != nil {
+		close(j.resume)
+		j.resume = nil
+	}
+}
+
+// returns channel which is closed on resume, or nil if job is not paused
+func (j *job) paused() <-chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.resume
+}
+
+// takeSlot takes place in chanel of VideoProcessor for job. While job is paused, worker is blocked on resume channel
+// without slot, so other videos can be processed. Returns false if ctx was done before slot was taken
+func (vP *VideoProcessor) takeSlot(ctx context.Context, j *job, vidInfo *Video) bool {
+	for {
+		if resume := j.paused(); resume != nil {
+			vP.setStatus(vidInfo, Paused, "paused by client")
+			select {
+			case <-resume:
+			case <-ctx.Done():
+				return false
+			}
+			vP.setStatus(vidInfo, InQueue, "resumed by client")
+			continue
+		}
+		select {
+		case vP.chanel <- struct{}{}:
+		case <-j.wake:
+			continue
+		case <-ctx.Done():
+			return false
+		}
+		//job could be paused while it was waiting for slot
+		if j.paused() != nil {
+			vP.releaseSlot()
+			continue
+		}
+		vP.setStatus(vidInfo, Processing, "processing started")
+		return true
+	}
+}
+
+func (vP *VideoProcessor) releaseSlot() {
+	<-vP.chanel
+}
@@ -0,0 +1,70 @@
+package recognizer
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Stages of processing of video, which may fail
+const (
+	//opening of uploaded file
+	StageOpen = "open"
+	//opening of annotated copy of video
+	StageAnnotate = "annotate"
+	//taking models from pool
+	StageModels = "models"
+	StageDetect = "detect"
+	//computing of descriptor of detected face
+	StageRecognize = "recognize"
+)
+
+// Policies of job on failure of frame
+const (
+	//video is moved to Error on the first failed frame
+	ErrorPolicyFailFast = "fail_fast"
+	//failed frame is skipped and processing continues
+	ErrorPolicySkip = "skip"
+)
+
+// JobError describes failure of processing of video. Failed job is canceled with JobError as its cause,
+// so it is also returned by context.Cause of job context
+type JobError struct {
+	//one of Stage* constants
+	Stage string `json:"stage"`
+	//index of frame, which was processed, or the frame processing was continued from for stages before frame loop
+	Frame   int64  `json:"frame"`
+	Message string `json:"message"`
+	err     error
+}
+
+func newJobError(stage string, frame int64, err error) *JobError {
+	return &JobError{Stage: stage, Frame: frame, Message: err.Error(), err: err}
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s failed on frame %d: %s", e.Stage, e.Frame, e.Message)
+}
+
+func (e *JobError) Unwrap() error {
+	return e.err
+}
+
+// fail moves video to Error with given error and cancels job context with it
+func (vP *VideoProcessor) fail(cancel context.CancelCauseFunc, vidInfo *Video, err *JobError) {
+	vidInfo.Error = err
+	vP.setStatus(vidInfo, Error, err.Error())
+	cancel(err)
+}
+
+// handles failure of frame according to policy of job, returns false if job was failed
+func (vP *VideoProcessor) frameFailed(cancel context.CancelCauseFunc, vidInfo *Video, err *JobError) bool {
+	if vidInfo.Options.OnError != ErrorPolicySkip {
+		vP.fail(cancel, vidInfo, err)
+		return false
+	}
+	log.Printf("video %d: frame is skipped: %s", vidInfo.Id, err.Error())
+	vidInfo.SkippedFrames++
+	vidInfo.LastSkipped = err
+	return true
+}
@@ -0,0 +1,59 @@
+package recognizer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waits until stored video gets given status
+func waitStatus(t *testing.T, vP *VideoProcessor, id int32, status VideoStatus) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		video, err := vP.GetVideo(id)
+		if err == nil && video.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("video %d has status %s, want %s", id, video.Status, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPauseOfQueuedJob(t *testing.T) {
+	//the only slot is taken by other video
+	vP := &VideoProcessor{chanel: make(chan struct{}, 1), store: NewMemoryStore(), jobs: make(map[int32]*job)}
+	vP.chanel <- struct{}{}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	j := newJob(cancel, false)
+	vP.jobs[1] = j
+	video := Video{Id: 1, Status: InQueue}
+	vP.save(video)
+
+	taken := make(chan bool)
+	go func() { taken <- vP.takeSlot(ctx, j, &video) }()
+
+	if err := vP.Pause(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := vP.Pause(1); err != nil {
+		t.Errorf("repeated pause of queued video: %v", err)
+	}
+	waitStatus(t, vP, 1, Paused)
+	if err := vP.Pause(1); err == nil {
+		t.Error("pause of paused video was accepted")
+	}
+
+	if err := vP.Resume(1); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, vP, 1, InQueue)
+	vP.releaseSlot()
+	if !<-taken {
+		t.Fatal("slot wasn't taken after resume")
+	}
+	waitStatus(t, vP, 1, Processing)
+}
@@ -0,0 +1,178 @@
+package recognizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Fields which videos can be sorted by
+const (
+	SortById        = "id"
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// ListQuery describes which videos should be returned by ListVideos. Zero values of filters match every video
+type ListQuery struct {
+	//videos having one of these statuses are returned
+	Statuses []VideoStatus
+	//substring of video name, it is matched case-insensitively
+	Name string
+	//bounds of video creation time, CreatedTo is exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	//one of Sort* constants, videos are sorted by id if it is empty
+	Sort string
+	Desc bool
+	//size of page, it is limited by maxPageSize
+	Limit int
+	//NextCursor of previous page
+	Cursor string
+}
+
+// VideoPage is one page of videos, NextCursor is empty on the last page
+type VideoPage struct {
+	Videos     []Video `json:"videos"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// cursor keeps sort keys of the last video of page, next page starts right after this video
+type cursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d"`
+	Id        int32     `json:"i"`
+	Name      string    `json:"n,omitempty"`
+	CreatedAt time.Time `json:"c"`
+	UpdatedAt time.Time `json:"u"`
+}
+
+// ListVideos returns page of videos matching query
+func (vP *VideoProcessor) ListVideos(query ListQuery) (VideoPage, error) {
+	less, err := videoOrder(query.Sort, query.Desc)
+	if err != nil {
+		return VideoPage{}, err
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	var videos []Video
+	for _, video := range vP.store.List() {
+		if query.matches(video) {
+			videos = append(videos, video)
+		}
+	}
+	sort.Slice(videos, func(i, j int) bool { return less(videos[i], videos[j]) })
+
+	if query.Cursor != "" {
+		last, err := query.decodeCursor()
+		if err != nil {
+			return VideoPage{}, err
+		}
+		start := sort.Search(len(videos), func(i int) bool { return less(last, videos[i]) })
+		videos = videos[start:]
+	}
+
+	page := VideoPage{Videos: videos}
+	if len(videos) > limit {
+		page.Videos = videos[:limit]
+		page.NextCursor = query.encodeCursor(videos[limit-1])
+	}
+	if page.Videos == nil {
+		page.Videos = []Video{}
+	}
+	return page, nil
+}
+
+func (query ListQuery) matches(video Video) bool {
+	if len(query.Statuses) > 0 {
+		found := false
+		for _, status := range query.Statuses {
+			found = found || status == video.Status
+		}
+		if !found {
+			return false
+		}
+	}
+	if query.Name != "" && !strings.Contains(strings.ToLower(video.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	if !query.CreatedFrom.IsZero() && video.CreatedAt.Before(query.CreatedFrom) {
+		return false
+	}
+	if !query.CreatedTo.IsZero() && !video.CreatedAt.Before(query.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// returns ordering of videos by given field, id is used when fields are equal, so ordering is strict
+func videoOrder(field string, desc bool) (func(a, b Video) bool, error) {
+	var compare func(a, b Video) int
+	switch field {
+	case "", SortById:
+		compare = func(a, b Video) int { return 0 }
+	case SortByName:
+		compare = func(a, b Video) int { return strings.Compare(a.Name, b.Name) }
+	case SortByCreatedAt:
+		compare = func(a, b Video) int { return a.CreatedAt.Compare(b.CreatedAt) }
+	case SortByUpdatedAt:
+		compare = func(a, b Video) int { return a.UpdatedAt.Compare(b.UpdatedAt) }
+	default:
+		return nil, fmt.Errorf("%w: unknown sort field %s", ErrInvalidQuery, field)
+	}
+	return func(a, b Video) bool {
+		result := compare(a, b)
+		if result == 0 {
+			result = int(a.Id) - int(b.Id)
+		}
+		if desc {
+			return result > 0
+		}
+		return result < 0
+	}, nil
+}
+
+func (query ListQuery) encodeCursor(last Video) string {
+	data, _ := json.Marshal(cursor{
+		Sort:      query.Sort,
+		Desc:      query.Desc,
+		Id:        last.Id,
+		Name:      last.Name,
+		CreatedAt: last.CreatedAt,
+		UpdatedAt: last.UpdatedAt})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// returns video with sort keys saved in cursor
+func (query ListQuery) decodeCursor() (Video, error) {
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return Video{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Video{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != query.Sort || c.Desc != query.Desc {
+		return Video{}, fmt.Errorf("%w: cursor was issued for another sorting", ErrInvalidQuery)
+	}
+	return Video{Id: c.Id, Name: c.Name, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}, nil
+}
@@ -0,0 +1,218 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// returns processor with videos 1-6, video 3 is updated last. Names are chosen so that sorting by name is case-sensitive
+// and videos 2 and 6 have equal names
+func listProcessor(t *testing.T) (*VideoProcessor, []Video) {
+	vP := &VideoProcessor{store: NewMemoryStore()}
+	created := []Video{
+		{Name: "Beach.mp4", Status: Successful},
+		{Name: "city.mp4", Status: Processing},
+		{Name: "beach_2.mp4", Status: InQueue},
+		{Name: "Forest.mp4", Status: Successful},
+		{Name: "alps.avi", Status: Error},
+		{Name: "city.mp4", Status: Paused},
+	}
+	for i := range created {
+		//videos get distinct timestamps
+		time.Sleep(time.Millisecond)
+		video, err := vP.store.Update(int32(i+1), func(video *Video) { *video = created[i] })
+		if err != nil {
+			t.Fatal(err)
+		}
+		created[i] = video
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := vP.store.Update(3, setStatus(Processing, "")); err != nil {
+		t.Fatal(err)
+	}
+	return vP, created
+}
+
+// returns ids of videos
+func ids(videos []Video) string {
+	result := make([]int32, len(videos))
+	for i, video := range videos {
+		result[i] = video.Id
+	}
+	return fmt.Sprint(result)
+}
+
+func TestListVideos(t *testing.T) {
+	vP, created := listProcessor(t)
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  string
+	}{
+		{"all", ListQuery{}, "[1 2 3 4 5 6]"},
+		{"status", ListQuery{Statuses: []VideoStatus{Successful}}, "[1 4]"},
+		{"several statuses", ListQuery{Statuses: []VideoStatus{InQueue, Processing}}, "[2 3]"},
+		{"name ignores case", ListQuery{Name: "BEACH"}, "[1 3]"},
+		{"name and status", ListQuery{Name: "city", Statuses: []VideoStatus{Paused}}, "[6]"},
+		{"created from", ListQuery{CreatedFrom: created[3].CreatedAt}, "[4 5 6]"},
+		{"created to is exclusive", ListQuery{CreatedTo: created[2].CreatedAt}, "[1 2]"},
+		{"created between", ListQuery{CreatedFrom: created[2].CreatedAt, CreatedTo: created[4].CreatedAt}, "[3 4]"},
+		{"nothing matches", ListQuery{Name: "desert"}, "[]"},
+		{"id desc", ListQuery{Sort: SortById, Desc: true}, "[6 5 4 3 2 1]"},
+		//equal names are ordered by id
+		{"name", ListQuery{Sort: SortByName}, "[1 4 5 3 2 6]"},
+		{"name desc", ListQuery{Sort: SortByName, Desc: true}, "[6 2 3 5 4 1]"},
+		{"created at", ListQuery{Sort: SortByCreatedAt}, "[1 2 3 4 5 6]"},
+		{"created at desc", ListQuery{Sort: SortByCreatedAt, Desc: true}, "[6 5 4 3 2 1]"},
+		{"updated at", ListQuery{Sort: SortByUpdatedAt}, "[1 2 4 5 6 3]"},
+		{"updated at desc with filter", ListQuery{Sort: SortByUpdatedAt, Desc: true, Statuses: []VideoStatus{Processing, Successful}},
+			"[3 4 2 1]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := vP.ListVideos(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(page.Videos); got != test.want {
+				t.Errorf("ListVideos returned %s, want %s", got, test.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("single page has next cursor %q", page.NextCursor)
+			}
+		})
+	}
+}
+
+// pages of every size and ordering make up the same list as one page
+func TestListVideosPages(t *testing.T) {
+	vP, _ := listProcessor(t)
+	for _, sort := range []string{"", SortById, SortByName, SortByCreatedAt, SortByUpdatedAt} {
+		for _, desc := range []bool{false, true} {
+			query := ListQuery{Sort: sort, Desc: desc, Statuses: []VideoStatus{Successful, Processing, InQueue, Paused}}
+			all, err := vP.ListVideos(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for limit := 1; limit <= len(all.Videos); limit++ {
+				query.Limit, query.Cursor = limit, ""
+				var videos []Video
+				for pages := 0; ; pages++ {
+					if pages > len(all.Videos) {
+						t.Fatalf("sort %q desc %v limit %d: pages don't end", sort, desc, limit)
+					}
+					page, err := vP.ListVideos(query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(page.Videos) > limit {
+						t.Fatalf("sort %q desc %v: page of limit %d has %d videos", sort, desc, limit, len(page.Videos))
+					}
+					videos = append(videos, page.Videos...)
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+				if ids(videos) != ids(all.Videos) {
+					t.Errorf("sort %q desc %v: pages of %d videos returned %s, want %s", sort, desc, limit, ids(videos), ids(all.Videos))
+				}
+			}
+		}
+	}
+}
+
+// next page starts right after the last video of previous page, even if list was changed between requests
+func TestListVideosCursorPosition(t *testing.T) {
+	vP, _ := listProcessor(t)
+	query := ListQuery{Sort: SortByName, Limit: 2}
+	page, err := vP.ListVideos(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(page.Videos); got != "[1 4]" {
+		t.Fatalf("first page is %s, want [1 4]", got)
+	}
+	//new video goes before cursor, and the last video of page is moved to the end by its new name
+	if _, err := vP.store.Update(7, func(video *Video) { video.Name, video.Status = "Alps.mp4", InQueue }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vP.store.Update(4, func(video *Video) { video.Name = "forest.mp4" }); err != nil {
+		t.Fatal(err)
+	}
+	query.Cursor = page.NextCursor
+	page, err = vP.ListVideos(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(page.Videos); got != "[5 3]" {
+		t.Errorf("second page is %s, want [5 3]", got)
+	}
+}
+
+func TestListVideosErrors(t *testing.T) {
+	vP, _ := listProcessor(t)
+	page, err := vP.ListVideos(ListQuery{Sort: SortByName, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query ListQuery
+	}{
+		{"unknown sort", ListQuery{Sort: "size"}},
+		{"cursor of other sort", ListQuery{Sort: SortByCreatedAt, Cursor: page.NextCursor}},
+		{"cursor of other direction", ListQuery{Sort: SortByName, Desc: true, Cursor: page.NextCursor}},
+		{"cursor of default sort", ListQuery{Cursor: page.NextCursor}},
+		{"cursor is not base64", ListQuery{Sort: SortByName, Cursor: "a b"}},
+		{"cursor is not json", ListQuery{Sort: SortByName, Cursor: "bm90IGpzb24"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := vP.ListVideos(test.query); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("ListVideos returned %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	last := Video{Id: 7, Name: "city.mp4", CreatedAt: created, UpdatedAt: created.Add(time.Minute)}
+	tests := []struct {
+		name    string
+		issued  ListQuery
+		decoded ListQuery
+		valid   bool
+	}{
+		{"same sort", ListQuery{Sort: SortByName}, ListQuery{Sort: SortByName}, true},
+		{"same descending sort", ListQuery{Sort: SortByUpdatedAt, Desc: true}, ListQuery{Sort: SortByUpdatedAt, Desc: true}, true},
+		//filters and limit may change between pages
+		{"other filters", ListQuery{Limit: 2}, ListQuery{Name: "city", Limit: 10}, true},
+		{"other sort", ListQuery{Sort: SortByName}, ListQuery{Sort: SortByCreatedAt}, false},
+		{"other direction", ListQuery{Sort: SortById}, ListQuery{Sort: SortById, Desc: true}, false},
+		//empty sort means sorting by id, but cursor keeps sort as it was requested
+		{"explicit default sort", ListQuery{}, ListQuery{Sort: SortById}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.decoded.Cursor = test.issued.encodeCursor(last)
+			video, err := test.decoded.decodeCursor()
+			if !test.valid {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("decodeCursor returned %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if video.Id != last.Id || video.Name != last.Name || !video.CreatedAt.Equal(last.CreatedAt) ||
+				!video.UpdatedAt.Equal(last.UpdatedAt) {
+				t.Errorf("decodeCursor returned %+v, want sort keys of %+v", video, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package recognizer
+
+import (
+	"context"
+	"sync"
+)
+
+// mailbox delivers items to its channel in order without blocking sender. Items, which must be kept, are queued
+// without limit, other items are dropped while size items wait for slow receiver. So receiver, which is not able to
+// keep up, doesn't block sender and still gets every important item, for example every status change of video
+type mailbox[T any] struct {
+	ch     chan T
+	size   int
+	mu     sync.Mutex
+	queue  []T
+	signal chan struct{}
+}
+
+func newMailbox[T any](size int) *mailbox[T] {
+	return &mailbox[T]{ch: make(chan T), size: size, signal: make(chan struct{}, 1)}
+}
+
+// push queues item, it is dropped if it isn't kept and queue is full
+func (m *mailbox[T]) push(item T, keep bool) {
+	m.mu.Lock()
+	if keep || len(m.queue) < m.size {
+		m.queue = append(m.queue, item)
+	}
+	m.mu.Unlock()
+	select {
+	case m.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run forwards queued items to channel until ctx is done, then channel is closed
+func (m *mailbox[T]) run(ctx context.Context) {
+	defer close(m.ch)
+	for {
+		select {
+		case <-m.signal:
+		case <-ctx.Done():
+			return
+		}
+		for {
+			m.mu.Lock()
+			if len(m.queue) == 0 {
+				m.queue = nil
+				m.mu.Unlock()
+				break
+			}
+			item := m.queue[0]
+			m.queue = m.queue[1:]
+			m.mu.Unlock()
+			select {
+			case m.ch <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package recognizer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// Kinds of matchers
+const (
+	// exact linear scan over every descriptor, it is fast enough for galleries of several hundreds of photos
+	MatcherBruteForce = "brute"
+	// approximate search in hierarchical navigable small world graph, it is needed for galleries of thousands of photos
+	MatcherHNSW = "hnsw"
+)
+
+// Match is a person found by Matcher with distance from descriptor to the closest descriptor of this person
+type Match struct {
+	Person   string  `json:"person"`
+	Distance float64 `json:"distance"`
+	//distance mapped to [0, 1] by calibration of metric, it isn't set by Matcher
+	Confidence float64 `json:"confidence"`
+	//amount of the closest descriptors of person, it is set only for knn aggregation
+	Votes int `json:"votes,omitempty"`
+}
+
+// Matcher is an index of descriptors of gallery, which finds persons closest to descriptor of detected face.
+// Every descriptor is stored under unique id, several descriptors may belong to one person.
+// Matcher isn't safe for concurrent changes, but concurrent searches are allowed
+type Matcher interface {
+	// Insert adds descriptor of person, descriptor with the same id is replaced
+	Insert(id, person string, descriptor face.Descriptor)
+	// Delete removes descriptor with given id, nothing is done if there is no such descriptor
+	Delete(id string)
+	// Search returns up to k different persons ordered by distance
+	Search(descriptor face.Descriptor, k int) []Match
+	// Len returns amount of stored descriptors
+	Len() int
+	// Clone returns independent copy of matcher, it is used for immutable gallery snapshots
+	Clone() Matcher
+	// Save writes matcher in binary format, Load reads it back replacing current content
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+}
+
+// NewMatcher returns empty matcher of given kind
+func NewMatcher(kind string) (Matcher, error) {
+	switch kind {
+	case "", MatcherBruteForce:
+		return NewBruteForceMatcher(), nil
+	case MatcherHNSW:
+		return NewHNSWMatcher(DefaultHNSWConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown matcher %q", kind)
+	}
+}
+
+// Вычисление евклидового расстояния.
+func euclidianDistance(a, b face.Descriptor) float64 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(float64(sum))
+}
+
+type matcherEntry struct {
+	person     string
+	descriptor face.Descriptor
+}
+
+// BruteForceMatcher compares descriptor with every stored descriptor
+type BruteForceMatcher struct {
+	entries map[string]matcherEntry
+}
+
+func NewBruteForceMatcher() *BruteForceMatcher {
+	return &BruteForceMatcher{entries: make(map[string]matcherEntry)}
+}
+
+func (m *BruteForceMatcher) Insert(id, person string, descriptor face.Descriptor) {
+	m.entries[id] = matcherEntry{person: person, descriptor: descriptor}
+}
+
+func (m *BruteForceMatcher) Delete(id string) {
+	delete(m.entries, id)
+}
+
+func (m *BruteForceMatcher) Len() int {
+	return len(m.entries)
+}
+
+// Функция поиска наиболее близких, заданному дескриптору, персон.
+func (m *BruteForceMatcher) Search(descriptor face.Descriptor, k int) []Match {
+	// Для каждой персоны ищем ближайший к заданному дескриптор персоны.
+	distances := make(map[string]float64)
+	for _, entry := range m.entries {
+		distance := euclidianDistance(entry.descriptor, descriptor)
+		if best, ok := distances[entry.person]; !ok || distance < best {
+			distances[entry.person] = distance
+		}
+	}
+	return topMatches(distances, k)
+}
+
+// returns k persons with the smallest distances, persons with equal distances are ordered by name
+func topMatches(distances map[string]float64, k int) []Match {
+	matches := make([]Match, 0, len(distances))
+	for person, distance := range distances {
+		matches = append(matches, Match{Person: person, Distance: distance})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Person < matches[j].Person
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+func (m *BruteForceMatcher) Clone() Matcher {
+	clone := &BruteForceMatcher{entries: make(map[string]matcherEntry, len(m.entries))}
+	for id, entry := range m.entries {
+		clone.entries[id] = entry
+	}
+	return clone
+}
+
+var bruteForceMagic = [4]byte{'G', 'C', 'V', 'B'}
+
+// Версия формата сохранённого BruteForceMatcher.
+const bruteForceVersion = 1
+
+func (m *BruteForceMatcher) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeMatcherHeader(bw, bruteForceMagic, bruteForceVersion, uint32(len(m.entries))); err != nil {
+		return err
+	}
+	for id, entry := range m.entries {
+		if err := writeEntry(bw, id, entry); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (m *BruteForceMatcher) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	count, err := readMatcherHeader(br, bruteForceMagic, bruteForceVersion)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]matcherEntry, min(count, maxPreallocated))
+	for i := uint32(0); i < count; i++ {
+		id, entry, err := readEntry(br)
+		if err != nil {
+			return err
+		}
+		entries[id] = entry
+	}
+	m.entries = entries
+	return nil
+}
+
+// every number is little endian, strings are prefixed by their length
+func writeMatcherHeader(w io.Writer, magic [4]byte, version, count uint32) error {
+	return binary.Write(w, binary.LittleEndian, struct {
+		Magic   [4]byte
+		Version uint32
+		Count   uint32
+	}{magic, version, count})
+}
+
+func readMatcherHeader(r io.Reader, magic [4]byte, version uint32) (uint32, error) {
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		Count   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return 0, fmt.Errorf("unable to read matcher header: %w", err)
+	}
+	if header.Magic != magic {
+		return 0, fmt.Errorf("unexpected matcher format %q", header.Magic[:])
+	}
+	if header.Version != version {
+		return 0, fmt.Errorf("matcher has version %d, expected %d", header.Version, version)
+	}
+	return header.Count, nil
+}
+
+func writeEntry(w io.Writer, id string, entry matcherEntry) error {
+	if err := writeString(w, id); err != nil {
+		return err
+	}
+	if err := writeString(w, entry.person); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, entry.descriptor)
+}
+
+func readEntry(r io.Reader) (string, matcherEntry, error) {
+	var entry matcherEntry
+	id, err := readString(r)
+	if err != nil {
+		return "", entry, err
+	}
+	if entry.person, err = readString(r); err != nil {
+		return "", entry, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &entry.descriptor); err != nil {
+		return "", entry, fmt.Errorf("unable to read descriptor: %w", err)
+	}
+	return id, entry, nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// Больше элементов заранее не выделяется: количество из повреждённого файла может быть огромным.
+const maxPreallocated = 1 << 16
+
+// longer strings are treated as damaged file
+const maxMatcherString = 4096
+
+func readString(r io.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", fmt.Errorf("unable to read string: %w", err)
+	}
+	if length > maxMatcherString {
+		return "", fmt.Errorf("string of length %d is too long", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", fmt.Errorf("unable to read string: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,275 @@
+package recognizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// descriptors of dlib are normalized, so random vectors are normalized too
+func randomDescriptors(n int, rng *rand.Rand) []face.Descriptor {
+	descriptors := make([]face.Descriptor, n)
+	for i := range descriptors {
+		for j := range descriptors[i] {
+			descriptors[i][j] = float32(rng.NormFloat64())
+		}
+		norm := float32(euclidianDistance(descriptors[i], face.Descriptor{}))
+		for j := range descriptors[i] {
+			descriptors[i][j] /= norm
+		}
+	}
+	return descriptors
+}
+
+func filledMatcher(kind string, descriptors []face.Descriptor) Matcher {
+	m, _ := NewMatcher(kind)
+	for i, descriptor := range descriptors {
+		//every person has 5 photos
+		m.Insert(fmt.Sprint(i), fmt.Sprint(i/5), descriptor)
+	}
+	return m
+}
+
+// both matchers should find the same persons in small gallery, where HNSW visits every node
+func TestMatchersAgree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	descriptors := randomDescriptors(60, rng)
+	exact := filledMatcher(MatcherBruteForce, descriptors)
+	graph := filledMatcher(MatcherHNSW, descriptors)
+	//replaced and deleted descriptors
+	for _, m := range []Matcher{exact, graph} {
+		m.Insert("3", "replaced", descriptors[59])
+		m.Delete("7")
+		m.Delete("unknown")
+	}
+	if exact.Len() != 59 || graph.Len() != 59 {
+		t.Fatalf("matchers have %d and %d descriptors, want 59", exact.Len(), graph.Len())
+	}
+	queries := append(randomDescriptors(20, rng), descriptors[3], descriptors[7], descriptors[59])
+	for i, query := range queries {
+		want, got := exact.Search(query, 3), graph.Search(query, 3)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("query %d: hnsw found %+v, brute force found %+v", i, got, want)
+		}
+	}
+}
+
+func TestHNSWInsertDelete(t *testing.T) {
+	descriptors := randomDescriptors(20, rand.New(rand.NewSource(1)))
+	m := NewHNSWMatcher(DefaultHNSWConfig)
+	for i, descriptor := range descriptors[:10] {
+		m.Insert(fmt.Sprint(i), fmt.Sprint("person", i), descriptor)
+	}
+	//descriptor with the same id is replaced
+	m.Insert("0", "other", descriptors[10])
+	if m.Len() != 10 {
+		t.Fatalf("Len = %d after replace, want 10", m.Len())
+	}
+	if matches := m.Search(descriptors[10], 1); len(matches) != 1 || matches[0].Person != "other" || matches[0].Distance != 0 {
+		t.Errorf("replaced descriptor wasn't found: %+v", matches)
+	}
+	if matches := m.Search(descriptors[0], 10); len(matches) != 10 || matches[0].Person == "person0" {
+		t.Errorf("old descriptor of replaced id was found: %+v", matches)
+	}
+	for i := 1; i < 4; i++ {
+		m.Delete(fmt.Sprint(i))
+	}
+	m.Delete(fmt.Sprint(1))
+	if m.Len() != 7 {
+		t.Fatalf("Len = %d after delete, want 7", m.Len())
+	}
+	for _, match := range m.Search(descriptors[1], 10) {
+		if match.Person == "person1" || match.Person == "person2" || match.Person == "person3" {
+			t.Errorf("deleted person %s was found", match.Person)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		m.Delete(fmt.Sprint(i))
+	}
+	if m.Len() != 0 || m.Search(descriptors[0], 1) != nil {
+		t.Fatalf("empty matcher has %d descriptors", m.Len())
+	}
+	m.Insert("new", "new", descriptors[11])
+	if matches := m.Search(descriptors[0], 1); len(matches) != 1 || matches[0].Person != "new" {
+		t.Errorf("matcher doesn't work after deleting everything: %+v", matches)
+	}
+}
+
+// graph is rebuilt from alive nodes, when more than half of nodes are deleted
+func TestHNSWRebuildAfterDeletes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	descriptors := randomDescriptors(100, rng)
+	m := filledMatcher(MatcherHNSW, descriptors).(*HNSWMatcher)
+	exact := filledMatcher(MatcherBruteForce, descriptors)
+	for i := 0; i < 51; i++ {
+		m.Delete(fmt.Sprint(i * 99 % 100))
+		exact.Delete(fmt.Sprint(i * 99 % 100))
+	}
+	if m.Len() != 49 || len(m.nodes) != m.Len() {
+		t.Fatalf("graph has %d nodes for %d descriptors, it wasn't rebuilt", len(m.nodes), m.Len())
+	}
+	for _, node := range m.nodes {
+		if node.deleted {
+			t.Fatalf("rebuilt graph has deleted node %s", node.id)
+		}
+	}
+	for i, query := range append(randomDescriptors(20, rng), descriptors...) {
+		if want, got := exact.Search(query, 3), m.Search(query, 3); !reflect.DeepEqual(got, want) {
+			t.Errorf("query %d: rebuilt hnsw found %+v, brute force found %+v", i, got, want)
+		}
+	}
+}
+
+// deleted nodes closest to query must not take places of alive nodes in results of search
+func TestHNSWSearchSkipsDeleted(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	queries := randomDescriptors(20, rng)
+	m := NewHNSWMatcher(HNSWConfig{M: 16, EfConstruction: 200, EfSearch: 1})
+	for i, descriptor := range randomDescriptors(100, rng) {
+		m.Insert(fmt.Sprint("alive", i), fmt.Sprint("alive", i), descriptor)
+	}
+	//4 copies of every query are closer than any alive node and fill all places of search with k = 1
+	for i, query := range queries {
+		for j, noise := range randomDescriptors(4, rng) {
+			for d := range noise {
+				noise[d] = query[d] + noise[d]/100
+			}
+			m.Insert(fmt.Sprint("deleted", i, j), "deleted", noise)
+		}
+	}
+	for i := range queries {
+		for j := 0; j < 4; j++ {
+			m.Delete(fmt.Sprint("deleted", i, j))
+		}
+	}
+	if len(m.nodes) == m.Len() {
+		t.Fatal("graph was rebuilt, deleted nodes aren't tested")
+	}
+	for i, query := range queries {
+		if matches := m.Search(query, 1); len(matches) != 1 || matches[0].Person == "deleted" {
+			t.Errorf("query %d: search found %+v", i, matches)
+		}
+	}
+}
+
+func TestMatcherSaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	descriptors := randomDescriptors(30, rng)
+	queries := randomDescriptors(10, rng)
+	for _, kind := range []string{MatcherBruteForce, MatcherHNSW} {
+		t.Run(kind, func(t *testing.T) {
+			m := filledMatcher(kind, descriptors)
+			//deleted nodes of graph are saved too
+			m.Delete("4")
+			m.Delete("10")
+			var buf bytes.Buffer
+			if err := m.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			loaded, _ := NewMatcher(kind)
+			if err := loaded.Load(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if loaded.Len() != m.Len() {
+				t.Fatalf("loaded matcher has %d descriptors, want %d", loaded.Len(), m.Len())
+			}
+			for i, query := range append(queries, descriptors...) {
+				if want, got := m.Search(query, 3), loaded.Search(query, 3); !reflect.DeepEqual(got, want) {
+					t.Errorf("query %d: loaded matcher found %+v, saved one found %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// damaged file is rejected and matcher keeps its content
+func TestMatcherLoadDamaged(t *testing.T) {
+	descriptors := randomDescriptors(6, rand.New(rand.NewSource(1)))
+	for _, kind := range []string{MatcherBruteForce, MatcherHNSW} {
+		t.Run(kind, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := filledMatcher(kind, descriptors).Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			damaged := map[string][]byte{}
+			for cut := 0; cut < len(data); cut++ {
+				damaged[fmt.Sprint("truncated to ", cut)] = data[:cut]
+			}
+			corrupt := func(name string, offset int, value uint32) {
+				copied := bytes.Clone(data)
+				binary.LittleEndian.PutUint32(copied[offset:], value)
+				damaged[name] = copied
+			}
+			corrupt("magic", 0, 0)
+			corrupt("version", 4, 2)
+			corrupt("huge count", 8, math.MaxUint32)
+			if kind == MatcherBruteForce {
+				corrupt("long string", 12, maxMatcherString+1)
+			}
+			if kind == MatcherHNSW {
+				//header, parameters, entry of the first node with id "0" and person "0", deleted flag, levels, links
+				params := 12
+				link := params + 20 + 5 + 5 + len(descriptors[0])*4 + 1 + 4 + 4
+				corrupt("long string", params+20, maxMatcherString+1)
+				corrupt("entry point", params+12, 100)
+				corrupt("link", link, 100)
+				corrupt("negative link", link, math.MaxUint32)
+				corrupt("levels", link-8, 100)
+			}
+			m := filledMatcher(kind, descriptors[:2])
+			for name, input := range damaged {
+				if err := m.Load(bytes.NewReader(input)); err == nil {
+					t.Errorf("%s: damaged file was loaded", name)
+				}
+				if matches := m.Search(descriptors[1], 5); m.Len() != 2 || len(matches) != 1 || matches[0].Person != "0" {
+					t.Fatalf("%s: matcher was changed by failed load: %+v", name, matches)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatcherSearch(b *testing.B) {
+	for _, kind := range []string{MatcherBruteForce, MatcherHNSW} {
+		for _, n := range []int{1000, 10000} {
+			rng := rand.New(rand.NewSource(1))
+			descriptors := randomDescriptors(n, rng)
+			m := filledMatcher(kind, descriptors)
+			exact := filledMatcher(MatcherBruteForce, descriptors)
+			queries := randomDescriptors(100, rng)
+			b.Run(fmt.Sprintf("%s/%d", kind, n), func(b *testing.B) {
+				found := 0
+				for i := 0; i < b.N; i++ {
+					query := queries[i%len(queries)]
+					matches := m.Search(query, 2)
+					b.StopTimer()
+					if len(matches) > 0 && matches[0] == exact.Search(query, 1)[0] {
+						found++
+					}
+					b.StartTimer()
+				}
+				//share of searches, which found the same closest person as exact search
+				b.ReportMetric(float64(found)/float64(b.N), "recall")
+			})
+		}
+	}
+}
+
+func BenchmarkMatcherInsert(b *testing.B) {
+	for _, kind := range []string{MatcherBruteForce, MatcherHNSW} {
+		descriptors := randomDescriptors(10000, rand.New(rand.NewSource(1)))
+		b.Run(kind, func(b *testing.B) {
+			m, _ := NewMatcher(kind)
+			for i := 0; i < b.N; i++ {
+				m.Insert(fmt.Sprint(i), fmt.Sprint(i/5), descriptors[i%len(descriptors)])
+			}
+		})
+	}
+}
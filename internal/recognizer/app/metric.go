@@ -0,0 +1,173 @@
+package recognizer
+
+import (
+	"math"
+	"sort"
+
+	"go_cv_test/internal/config"
+	face "go_cv_test/internal/recognizer"
+)
+
+// Metrics of distance between descriptors
+const (
+	MetricEuclidean = "euclidean"
+	//1 - cosine similarity
+	MetricCosine = "cosine"
+)
+
+// Ways to get distance to person from distances to descriptors of its photos
+const (
+	//distance to the closest photo of person
+	AggregateNearest = "nearest"
+	//distance to mean descriptor of person
+	AggregateCentroid = "centroid"
+	//persons are ordered by amount of votes among the closest descriptors of the whole gallery
+	AggregateKNN = "knn"
+)
+
+// Количество ближайших дескрипторов галереи, которые голосуют за персону при AggregateKNN.
+const knnNeighbors = 5
+
+// Calibration maps distance to confidence in [0, 1] with logistic function: confidence is 0.5 at Midpoint and it
+// changes from 0.73 to 0.27 within Scale around Midpoint
+type Calibration struct {
+	Midpoint float64 `json:"midpoint"`
+	Scale    float64 `json:"scale"`
+}
+
+func (c Calibration) confidence(distance float64) float64 {
+	return 1 / (1 + math.Exp((distance-c.Midpoint)/c.Scale))
+}
+
+// global thresholds of every metric, they are overridden by jobs and persons
+func metricThresholds(r config.Recognition) map[string]Thresholds {
+	return map[string]Thresholds{MetricEuclidean: metricThreshold(r.Euclidean), MetricCosine: metricThreshold(r.Cosine)}
+}
+
+// global margin and ratio of knn aggregation, they replace ones of metric
+func voteThresholds(r config.Recognition) Thresholds {
+	return Thresholds{MinMargin: &r.KNN.MinMargin, MaxRatio: &r.KNN.MaxRatio}
+}
+
+// calibrations of every metric
+func calibrations(r config.Recognition) map[string]Calibration {
+	return map[string]Calibration{
+		MetricEuclidean: {Midpoint: r.Euclidean.CalibrationMidpoint, Scale: r.Euclidean.CalibrationScale},
+		MetricCosine:    {Midpoint: r.Cosine.CalibrationMidpoint, Scale: r.Cosine.CalibrationScale}}
+}
+
+func metricDistance(metric string, a, b face.Descriptor) float64 {
+	if metric == MetricCosine {
+		return cosineDistance(a, b)
+	}
+	return euclidianDistance(a, b)
+}
+
+// Вычисление косинусного расстояния, для нулевого вектора оно равно 1.
+func cosineDistance(a, b face.Descriptor) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+
+// returns mean descriptor of every person, persons without photos are skipped
+func centroids(persons []Person) map[string]face.Descriptor {
+	result := make(map[string]face.Descriptor, len(persons))
+	for _, person := range persons {
+		if len(person.Descriptors) == 0 {
+			continue
+		}
+		var centroid face.Descriptor
+		for _, descriptor := range person.Descriptors {
+			for i := range centroid {
+				centroid[i] += descriptor[i]
+			}
+		}
+		for i := range centroid {
+			centroid[i] /= float32(len(person.Descriptors))
+		}
+		result[person.Name] = centroid
+	}
+	return result
+}
+
+// search returns up to k the closest persons with metric and aggregation of job. Only euclidean distance to the
+// nearest photo is searched in index of gallery, other ways are exact scans of snapshot
+func (r recognition) search(gallery *GallerySnapshot, descriptor face.Descriptor, k int) []Match {
+	var matches []Match
+	switch {
+	case r.aggregation == AggregateCentroid:
+		distances := make(map[string]float64, len(gallery.Centroids))
+		for name, centroid := range gallery.Centroids {
+			distances[name] = metricDistance(r.metric, centroid, descriptor)
+		}
+		matches = topMatches(distances, k)
+	case r.aggregation == AggregateKNN:
+		matches = r.vote(gallery, descriptor, k)
+	case r.metric == MetricEuclidean:
+		matches = gallery.Matcher.Search(descriptor, k)
+	default:
+		distances := make(map[string]float64, len(gallery.Persons))
+		for _, person := range gallery.Persons {
+			for _, known := range person.Descriptors {
+				distance := metricDistance(r.metric, known, descriptor)
+				if best, ok := distances[person.Name]; !ok || distance < best {
+					distances[person.Name] = distance
+				}
+			}
+		}
+		matches = topMatches(distances, k)
+	}
+	for i := range matches {
+		matches[i].Confidence = r.calibration.confidence(matches[i].Distance)
+	}
+	return matches
+}
+
+// knnNeighbors the closest descriptors of gallery vote for their persons. Persons are ordered by votes, then by mean
+// distance of their voting descriptors, which is returned as distance to person
+func (r recognition) vote(gallery *GallerySnapshot, descriptor face.Descriptor, k int) []Match {
+	var neighbors []Match
+	for _, person := range gallery.Persons {
+		for _, known := range person.Descriptors {
+			neighbors = append(neighbors, Match{Person: person.Name, Distance: metricDistance(r.metric, known, descriptor)})
+		}
+	}
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].Distance < neighbors[j].Distance })
+	votes := make(map[string]*Match)
+	var matches []*Match
+	for _, neighbor := range neighbors[:min(len(neighbors), knnNeighbors)] {
+		match, ok := votes[neighbor.Person]
+		if !ok {
+			match = &Match{Person: neighbor.Person}
+			votes[neighbor.Person] = match
+			matches = append(matches, match)
+		}
+		match.Votes++
+		match.Distance += neighbor.Distance
+	}
+	for _, match := range matches {
+		match.Distance /= float64(match.Votes)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Votes != matches[j].Votes {
+			return matches[i].Votes > matches[j].Votes
+		}
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Person < matches[j].Person
+	})
+	result := make([]Match, 0, min(len(matches), k))
+	for _, match := range matches[:min(len(matches), k)] {
+		result = append(result, *match)
+	}
+	return result
+}
@@ -0,0 +1,261 @@
+package recognizer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+// Максимальное кол-во наборов моделей в пуле. Каждый набор занимает около 120 МБ памяти.
+const maxModelSets = 4
+
+// Файлы моделей, из которых состоит один набор.
+const (
+	detectorModel   = "mmod_human_face_detector.dat"
+	shaperModel     = "shape_predictor_68_face_landmarks.dat"
+	recognizerModel = "dlib_face_recognition_resnet_model_v1.dat"
+)
+
+var (
+	ErrPoolClosed      = errors.New("model pool is closed")
+	ErrModelsNotLoaded = errors.New("models are not loaded")
+)
+
+// models is one set of loaded dlib networks
+type models struct {
+	detector   *face.Detector
+	recognizer *face.Recognizer
+	//parameters of vectorization, descriptors computed with other ones aren't comparable
+	padding   float64
+	jittering int
+}
+
+// recognize computes descriptor of face in rect with parameters of vectorization of pool
+func (m *models) recognize(img gocv.Mat, rect image.Rectangle) (face.Descriptor, error) {
+	return m.recognizer.Recognize(img, rect, m.padding, m.jittering)
+}
+
+// recognizeAll computes descriptors of every face of image in one pass of network
+func (m *models) recognizeAll(img gocv.Mat, rects []image.Rectangle) ([]face.Descriptor, error) {
+	return m.recognizer.RecognizeBatch(img, rects, m.padding, m.jittering)
+}
+
+func (m *models) close() {
+	if m.detector != nil {
+		m.detector.Close()
+	}
+	if m.recognizer != nil {
+		m.recognizer.Close()
+	}
+}
+
+// ModelPool keeps sets of models loaded once at startup and shared by all workers. Every set guards its networks
+// with net_mutex on C++ side, so set processes one frame at a time no matter how many goroutines use it. That is why
+// set is taken from pool for processing of one batch of frames, and size of pool is the number of batches processed in parallel:
+// pool bigger than number of workers only wastes memory, smaller one makes workers wait for each other
+type ModelPool struct {
+	size int
+	free chan *models
+	//error of loading models, pool can't be used if it is set
+	err error
+	//sha256 of model files, descriptors computed by other models are not comparable with ours
+	checksum [sha256.Size]byte
+
+	mu     sync.RWMutex
+	closed bool
+	//it is closed by Close, so waiting Acquire doesn't block forever
+	done chan struct{}
+
+	inUse        atomic.Int32
+	waiting      atomic.Int32
+	acquisitions atomic.Int64
+	waitNanos    atomic.Int64
+}
+
+// PoolHealth describes state of ModelPool
+type PoolHealth struct {
+	Healthy      bool   `json:"healthy"`
+	Size         int    `json:"size"`
+	InUse        int    `json:"in_use"`
+	Waiting      int    `json:"waiting"`
+	Acquisitions int64  `json:"acquisitions"`
+	AvgWaitMs    int64  `json:"avg_wait_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// LoadModelPool loads size sets of models from modelsPath, they compute descriptors with given padding and jittering.
+// If loading fails, pool keeps the error: it is reported by Health and returned by Acquire, so service still can
+// serve results of processed videos
+func LoadModelPool(modelsPath string, size int, padding float64, jittering int) *ModelPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &ModelPool{size: size, free: make(chan *models, size), done: make(chan struct{})}
+	started := time.Now()
+	var err error
+	if p.checksum, err = modelsChecksum(modelsPath); err != nil {
+		p.err = fmt.Errorf("%w: %w", ErrModelsNotLoaded, err)
+		return p
+	}
+	for i := 0; i < size; i++ {
+		m, err := loadModels(modelsPath)
+		if err != nil {
+			p.err = fmt.Errorf("%w: %w", ErrModelsNotLoaded, err)
+			p.closeFree()
+			return p
+		}
+		m.padding, m.jittering = padding, jittering
+		p.free <- m
+	}
+	log.Printf("%d model sets were loaded in %s", size, time.Since(started))
+	return p
+}
+
+func loadModels(modelsPath string) (*models, error) {
+	// Инициализация детектора лиц, который будет выявлять лица.
+	detector, err := face.NewDetector(path.Join(modelsPath, detectorModel))
+	if err != nil {
+		return nil, fmt.Errorf("unable to init face detector: %w", err)
+	}
+
+	// Инициализация распознавателя лиц, который будет векторизовывать лица.
+	recognizer, err := face.NewRecognizer(
+		path.Join(modelsPath, shaperModel),
+		path.Join(modelsPath, recognizerModel))
+	if err != nil {
+		detector.Close()
+		return nil, fmt.Errorf("unable to init face recognizer: %w", err)
+	}
+	return &models{detector: detector, recognizer: recognizer}, nil
+}
+
+// returns sha256 of content of every model file
+func modelsChecksum(modelsPath string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	hash := sha256.New()
+	for _, name := range []string{detectorModel, shaperModel, recognizerModel} {
+		file, err := os.Open(path.Join(modelsPath, name))
+		if err != nil {
+			return sum, fmt.Errorf("unable to read model: %w", err)
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return sum, fmt.Errorf("unable to read model %s: %w", name, err)
+		}
+	}
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
+
+// Checksum returns sha256 of loaded model files
+func (p *ModelPool) Checksum() [sha256.Size]byte {
+	return p.checksum
+}
+
+// Acquire takes set of models from pool, it waits until some set is released. Set must be given back with Release
+func (p *ModelPool) Acquire(ctx context.Context) (*models, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return nil, ErrPoolClosed
+	}
+
+	started := time.Now()
+	p.waiting.Add(1)
+	defer p.waiting.Add(-1)
+	select {
+	case m := <-p.free:
+		//set may be taken together with closing of pool, then Close waits for it
+		select {
+		case <-p.done:
+			p.free <- m
+			return nil, ErrPoolClosed
+		default:
+		}
+		p.inUse.Add(1)
+		p.acquisitions.Add(1)
+		p.waitNanos.Add(int64(time.Since(started)))
+		return m, nil
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// Release gives set of models back to pool
+func (p *ModelPool) Release(m *models) {
+	p.inUse.Add(-1)
+	p.free <- m
+}
+
+func (p *ModelPool) Health() PoolHealth {
+	health := PoolHealth{
+		Healthy:      p.err == nil,
+		Size:         p.size,
+		InUse:        int(p.inUse.Load()),
+		Waiting:      int(p.waiting.Load()),
+		Acquisitions: p.acquisitions.Load(),
+	}
+	if health.Acquisitions > 0 {
+		health.AvgWaitMs = p.waitNanos.Load() / health.Acquisitions / int64(time.Millisecond)
+	}
+	p.mu.RLock()
+	if p.closed {
+		health.Healthy = false
+		health.Error = ErrPoolClosed.Error()
+	}
+	p.mu.RUnlock()
+	if p.err != nil {
+		health.Error = p.err.Error()
+	}
+	return health
+}
+
+// Close waits until every set is given back and frees them, waiting Acquire returns ErrPoolClosed at once
+func (p *ModelPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	for i := 0; i < p.size; i++ {
+		(<-p.free).close()
+	}
+}
+
+// frees sets loaded before loading error
+func (p *ModelPool) closeFree() {
+	for {
+		select {
+		case m := <-p.free:
+			m.close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package recognizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// returns pool of given amount of empty sets, they aren't able to recognize anything, but can be acquired and closed
+func emptyPool(size int) *ModelPool {
+	p := &ModelPool{size: size, free: make(chan *models, size), done: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		p.free <- &models{}
+	}
+	return p
+}
+
+// Acquire waiting for set doesn't block forever, when pool is closed
+func TestPoolCloseWakesAcquire(t *testing.T) {
+	p := emptyPool(1)
+	m, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan error)
+	go func() {
+		_, err := p.Acquire(context.Background())
+		acquired <- err
+	}()
+	for p.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case err := <-acquired:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("waiting Acquire returned %v, want ErrPoolClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting Acquire wasn't woken by Close")
+	}
+	//Close waits for set in use
+	select {
+	case <-closed:
+		t.Fatal("Close returned before set was released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Release(m)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't return after release")
+	}
+
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Acquire of closed pool returned %v, want ErrPoolClosed", err)
+	}
+	if health := p.Health(); health.Healthy || health.Waiting != 0 || health.InUse != 0 {
+		t.Errorf("closed pool has health %+v", health)
+	}
+}
+
+func TestPoolAcquireCanceled(t *testing.T) {
+	p := emptyPool(1)
+	defer p.Close()
+	m, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release(m)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cause := errors.New("job is paused")
+	cancel(cause)
+	if _, err := p.Acquire(ctx); !errors.Is(err, cause) {
+		t.Errorf("Acquire returned %v, want cause of cancellation", err)
+	}
+}
@@ -0,0 +1,218 @@
+package recognizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"go_cv_test/internal/config"
+)
+
+// Метка лица, которое не удалось уверенно сопоставить ни с одной персоной.
+const Unknown = "unknown"
+
+// Имя файла с порогами персоны в папке персоны.
+const thresholdsFile = "thresholds.json"
+
+var ErrInvalidThresholds = errors.New("invalid thresholds")
+
+// Reasons, why the closest person was rejected
+const (
+	RejectedDistance = "distance"
+	RejectedMargin   = "margin"
+	RejectedRatio    = "ratio"
+)
+
+// Thresholds of recognition decision. Unset fields are inherited: global thresholds are overridden by thresholds
+// of job, and they are overridden by thresholds of the closest person
+type Thresholds struct {
+	//max distance to the closest person
+	MaxDistance *float64 `json:"max_distance,omitempty"`
+	//min difference between distances to the second and to the closest person
+	MinMargin *float64 `json:"min_margin,omitempty"`
+	//max ratio of distances to the closest and to the second person
+	MaxRatio *float64 `json:"max_ratio,omitempty"`
+}
+
+// MetricThresholds are thresholds of person by metric, because distances of different metrics have different scales.
+// Job uses thresholds of its metric
+type MetricThresholds map[string]Thresholds
+
+// Validate checks metrics and their thresholds
+func (m MetricThresholds) Validate() error {
+	for metric, t := range m {
+		if metric != MetricEuclidean && metric != MetricCosine {
+			return fmt.Errorf("%w: metric %q should be %s or %s", ErrInvalidThresholds, metric, MetricEuclidean, MetricCosine)
+		}
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", metric, err)
+		}
+	}
+	return nil
+}
+
+func (m MetricThresholds) empty() bool {
+	for _, t := range m {
+		if !t.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// returns global thresholds of metric from settings
+func metricThreshold(m config.Metric) Thresholds {
+	return Thresholds{MaxDistance: &m.MaxDistance, MinMargin: &m.MinMargin, MaxRatio: &m.MaxRatio}
+}
+
+// override returns thresholds, whose fields set in o are replaced
+func (t Thresholds) override(o Thresholds) Thresholds {
+	if o.MaxDistance != nil {
+		t.MaxDistance = o.MaxDistance
+	}
+	if o.MinMargin != nil {
+		t.MinMargin = o.MinMargin
+	}
+	if o.MaxRatio != nil {
+		t.MaxRatio = o.MaxRatio
+	}
+	return t
+}
+
+func (t Thresholds) empty() bool {
+	return t.MaxDistance == nil && t.MinMargin == nil && t.MaxRatio == nil
+}
+
+// Validate checks set fields
+func (t Thresholds) Validate() error {
+	if t.MaxDistance != nil && *t.MaxDistance <= 0 {
+		return fmt.Errorf("%w: max_distance should be positive", ErrInvalidThresholds)
+	}
+	if t.MinMargin != nil && *t.MinMargin < 0 {
+		return fmt.Errorf("%w: min_margin shouldn't be negative", ErrInvalidThresholds)
+	}
+	if t.MaxRatio != nil && (*t.MaxRatio <= 0 || *t.MaxRatio > 1) {
+		return fmt.Errorf("%w: max_ratio should be in (0, 1]", ErrInvalidThresholds)
+	}
+	return nil
+}
+
+// recognition are parameters of recognition decision of one job
+type recognition struct {
+	//one of Metric* and Aggregate* constants
+	metric      string
+	aggregation string
+	//global thresholds of metric or of knn overridden by thresholds of job
+	thresholds  Thresholds
+	calibration Calibration
+	//amount of the closest persons kept for every face
+	candidates int
+}
+
+// returns parameters of recognition for job with given options
+func (vP *VideoProcessor) recognition(options JobOptions) recognition {
+	r := recognition{metric: options.Metric, aggregation: options.Aggregation, candidates: vP.candidates}
+	if r.metric == "" {
+		r.metric = MetricEuclidean
+	}
+	if r.aggregation == "" {
+		r.aggregation = AggregateNearest
+	}
+	r.thresholds = vP.thresholds[r.metric]
+	if r.aggregation == AggregateKNN {
+		r.thresholds = r.thresholds.override(vP.votes)
+	}
+	r.thresholds = r.thresholds.override(options.Thresholds)
+	r.calibration = vP.calibrations[r.metric]
+	if options.Candidates > 0 {
+		r.candidates = options.Candidates
+	}
+	return r
+}
+
+// decide returns label of face with given closest persons: name of the closest person or Unknown together with name
+// of failed test. Thresholds of the closest person for metric of job override thresholds of job
+func (r recognition) decide(matches []Match, persons map[string]MetricThresholds) (string, string) {
+	if len(matches) == 0 {
+		return Unknown, RejectedDistance
+	}
+	best := matches[0]
+	personal := persons[best.Person][r.metric]
+	if r.aggregation == AggregateKNN {
+		//margin and ratio of person are distances of metric, they don't fit shares of votes
+		personal.MinMargin, personal.MaxRatio = nil, nil
+	}
+	t := r.thresholds.override(personal)
+	if best.Distance > *t.MaxDistance {
+		return Unknown, RejectedDistance
+	}
+	if len(matches) > 1 {
+		margin, ratio := r.separation(best, matches[1])
+		if margin < *t.MinMargin {
+			return Unknown, RejectedMargin
+		}
+		if ratio > *t.MaxRatio {
+			return Unknown, RejectedRatio
+		}
+	}
+	return best.Person, ""
+}
+
+// separation returns margin and ratio between the closest and the second person. Persons of knn are ordered by votes,
+// so the second person may be closer than the first one, and they are compared by shares of votes instead of distances
+func (r recognition) separation(best, second Match) (float64, float64) {
+	if r.aggregation == AggregateKNN {
+		return float64(best.Votes-second.Votes) / knnNeighbors, float64(second.Votes) / float64(max(1, best.Votes))
+	}
+	ratio := 0.0
+	if second.Distance > 0 {
+		ratio = best.Distance / second.Distance
+	}
+	return second.Distance - best.Distance, ratio
+}
+
+// reads thresholds of person from its folder, missing file gives empty thresholds
+func readThresholds(personsPath, name string) (MetricThresholds, error) {
+	var t MetricThresholds
+	data, err := os.ReadFile(path.Join(personsPath, name, thresholdsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidThresholds, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// writes thresholds of person to its folder, empty thresholds remove file
+func writeThresholds(personsPath, name string, t MetricThresholds) error {
+	file := path.Join(personsPath, name, thresholdsFile)
+	if t.empty() {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(t, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0640)
+}
+
+// SetPersonThresholds sets thresholds by metric, which override thresholds of jobs with this metric for given person,
+// empty thresholds remove them
+func (vP *VideoProcessor) SetPersonThresholds(name string, t MetricThresholds) (PersonInfo, error) {
+	if err := t.Validate(); err != nil {
+		return PersonInfo{}, err
+	}
+	return vP.gallery.setThresholds(name, t)
+}
@@ -0,0 +1,150 @@
+package recognizer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	face "go_cv_test/internal/recognizer"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestMetricThresholdsValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds MetricThresholds
+		valid      bool
+	}{
+		{"empty", nil, true},
+		{"both metrics", MetricThresholds{MetricEuclidean: {MaxDistance: float(0.45)}, MetricCosine: {MaxRatio: float(0.8)}}, true},
+		{"unknown metric", MetricThresholds{"manhattan": {MaxDistance: float(0.45)}}, false},
+		{"invalid thresholds", MetricThresholds{MetricCosine: {MaxRatio: float(1.5)}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.thresholds.Validate()
+			if test.valid && err != nil {
+				t.Errorf("valid thresholds were rejected: %v", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidThresholds) {
+				t.Errorf("Validate returned %v, want ErrInvalidThresholds", err)
+			}
+		})
+	}
+}
+
+// thresholds.json keeps thresholds by metric, file in old format without metrics is rejected
+func TestReadThresholds(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"alice": `{"cosine": {"max_distance": 0.1}}`,
+		"bob":   `{"max_distance": 0.45}`,
+	} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, thresholdsFile), []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice, err := readThresholds(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := alice[MetricEuclidean]; ok || alice[MetricCosine].MaxDistance == nil || *alice[MetricCosine].MaxDistance != 0.1 {
+		t.Errorf("readThresholds returned %+v", alice)
+	}
+	if _, err := readThresholds(dir, "bob"); !errors.Is(err, ErrInvalidThresholds) {
+		t.Errorf("thresholds without metric were read with error %v", err)
+	}
+	if missing, err := readThresholds(dir, "carol"); err != nil || !missing.empty() {
+		t.Errorf("missing file gave %+v, %v", missing, err)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	thresholds := Thresholds{MaxDistance: float(0.5), MinMargin: float(0.05), MaxRatio: float(0.9)}
+	tests := []struct {
+		name        string
+		aggregation string
+		matches     []Match
+		persons     map[string]MetricThresholds
+		label       string
+		rejection   string
+	}{
+		{"no matches", AggregateNearest, nil, nil, Unknown, RejectedDistance},
+		{"single match", AggregateNearest, []Match{{Person: "alice", Distance: 0.4}}, nil, "alice", ""},
+		{"far match", AggregateNearest, []Match{{Person: "alice", Distance: 0.6}}, nil, Unknown, RejectedDistance},
+		{"separated", AggregateNearest, []Match{{Person: "alice", Distance: 0.3}, {Person: "bob", Distance: 0.45}}, nil, "alice", ""},
+		{"small margin", AggregateNearest, []Match{{Person: "alice", Distance: 0.3}, {Person: "bob", Distance: 0.32}}, nil, Unknown, RejectedMargin},
+		{"big ratio", AggregateNearest, []Match{{Person: "alice", Distance: 0.45}, {Person: "bob", Distance: 0.49}},
+			map[string]MetricThresholds{"alice": {MetricEuclidean: {MinMargin: float(0)}}}, Unknown, RejectedRatio},
+		{"person threshold", AggregateNearest, []Match{{Person: "alice", Distance: 0.6}},
+			map[string]MetricThresholds{"alice": {MetricEuclidean: {MaxDistance: float(0.7)}}}, "alice", ""},
+		{"threshold of other person", AggregateNearest, []Match{{Person: "alice", Distance: 0.6}},
+			map[string]MetricThresholds{"bob": {MetricEuclidean: {MaxDistance: float(0.7)}}}, Unknown, RejectedDistance},
+		{"threshold of other metric", AggregateNearest, []Match{{Person: "alice", Distance: 0.6}},
+			map[string]MetricThresholds{"alice": {MetricCosine: {MaxDistance: float(0.7)}}}, Unknown, RejectedDistance},
+		//winner of voting is farther than the second person, distances would give negative margin
+		{"knn winner", AggregateKNN, []Match{{Person: "alice", Distance: 0.4, Votes: 3}, {Person: "bob", Distance: 0.2, Votes: 2}},
+			nil, "alice", ""},
+		{"knn tie", AggregateKNN, []Match{{Person: "alice", Distance: 0.2, Votes: 2}, {Person: "bob", Distance: 0.4, Votes: 2}},
+			nil, Unknown, RejectedMargin},
+		{"knn vote ratio", AggregateKNN, []Match{{Person: "alice", Distance: 0.3, Votes: 4}, {Person: "bob", Distance: 0.2, Votes: 1}},
+			nil, "alice", ""},
+		{"knn one vote more", AggregateKNN, []Match{{Person: "alice", Distance: 0.3, Votes: 3}, {Person: "bob", Distance: 0.2, Votes: 2}},
+			nil, "alice", ""},
+		//margin and ratio of person are distances, they aren't used for votes
+		{"knn ignores person margin", AggregateKNN, []Match{{Person: "alice", Distance: 0.3, Votes: 2}, {Person: "bob", Distance: 0.2, Votes: 2}},
+			map[string]MetricThresholds{"alice": {MetricEuclidean: {MinMargin: float(0), MaxRatio: float(1)}}}, Unknown, RejectedMargin},
+		{"knn person distance", AggregateKNN, []Match{{Person: "alice", Distance: 0.6, Votes: 5}},
+			map[string]MetricThresholds{"alice": {MetricEuclidean: {MaxDistance: float(0.7)}}}, "alice", ""},
+		{"knn far winner", AggregateKNN, []Match{{Person: "alice", Distance: 0.6, Votes: 5}}, nil, Unknown, RejectedDistance},
+	}
+	vP := &VideoProcessor{thresholds: map[string]Thresholds{MetricEuclidean: thresholds},
+		votes: Thresholds{MinMargin: float(0.2), MaxRatio: float(0.75)}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := vP.recognition(JobOptions{Aggregation: test.aggregation})
+			label, rejection := r.decide(test.matches, test.persons)
+			if label != test.label || rejection != test.rejection {
+				t.Errorf("decide returned %q, %q, want %q, %q", label, rejection, test.label, test.rejection)
+			}
+		})
+	}
+}
+
+// job overrides vote thresholds of knn
+func TestDecideJobVotes(t *testing.T) {
+	vP := &VideoProcessor{thresholds: map[string]Thresholds{MetricEuclidean: {MaxDistance: float(0.5), MinMargin: float(0.05), MaxRatio: float(0.9)}},
+		votes: Thresholds{MinMargin: float(0.2), MaxRatio: float(0.75)}}
+	r := vP.recognition(JobOptions{Aggregation: AggregateKNN, Thresholds: Thresholds{MaxRatio: float(0.5)}})
+	matches := []Match{{Person: "alice", Distance: 0.3, Votes: 3}, {Person: "bob", Distance: 0.2, Votes: 2}}
+	if label, rejection := r.decide(matches, nil); label != Unknown || rejection != RejectedRatio {
+		t.Errorf("decide returned %q, %q, want rejection by ratio", label, rejection)
+	}
+}
+
+// closer person with fewer votes doesn't reject winner of voting
+func TestDecideVotes(t *testing.T) {
+	var bob, alice face.Descriptor
+	bob[0] = 0.1
+	gallery := &GallerySnapshot{Persons: []Person{
+		{Name: "bob", Descriptors: []face.Descriptor{bob}},
+		{Name: "alice", Descriptors: []face.Descriptor{alice, alice, alice, alice}}}}
+	r := recognition{metric: MetricEuclidean, aggregation: AggregateKNN,
+		thresholds: Thresholds{MaxDistance: float(0.5), MinMargin: float(0.2), MaxRatio: float(0.75)}}
+	query := face.Descriptor{}
+	query[0] = 0.2
+	matches := r.search(gallery, query, 2)
+	if len(matches) != 2 || matches[0].Person != "alice" || matches[0].Votes != 4 || matches[1].Distance >= matches[0].Distance {
+		t.Fatalf("search returned %+v", matches)
+	}
+	if label, rejection := r.decide(matches, nil); label != "alice" {
+		t.Errorf("decide returned %q, rejected by %s", label, rejection)
+	}
+}
@@ -0,0 +1,103 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidSampling = errors.New("invalid sampling")
+
+// Sampling chooses frames of video, which are analyzed. At most one field may be set, zero Sampling analyzes every frame
+type Sampling struct {
+	//every Stride-th frame is analyzed
+	Stride int `json:"stride,omitempty"`
+	//frames are analyzed with this rate, it is limited by fps of video
+	TargetFPS float64 `json:"target_fps,omitempty"`
+	//one frame of every interval of video is analyzed
+	IntervalMs float64 `json:"interval_ms,omitempty"`
+}
+
+// Validate checks that only one way of sampling is chosen
+func (s Sampling) Validate() error {
+	if s.Stride < 0 || s.TargetFPS < 0 || s.IntervalMs < 0 {
+		return fmt.Errorf("%w: stride, target_fps and interval_ms shouldn't be negative", ErrInvalidSampling)
+	}
+	set := 0
+	for _, ok := range []bool{s.Stride > 0, s.TargetFPS > 0, s.IntervalMs > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("%w: only one of stride, target_fps and interval_ms may be set", ErrInvalidSampling)
+	}
+	return nil
+}
+
+// sampler decides by index of frame, whether it is analyzed, so the same frames are chosen after restart from checkpoint
+type sampler struct {
+	stride int64
+	//duration of frame and interval between analyzed frames, interval is 0 for stride sampling
+	frameMs    float64
+	intervalMs float64
+}
+
+// returns sampler for video with given fps. Time based sampling needs fps, without it every frame is analyzed
+func newSampler(s Sampling, fps float64) sampler {
+	result := sampler{stride: 1}
+	switch {
+	case s.Stride > 1:
+		result.stride = int64(s.Stride)
+	case fps <= 0:
+	case s.TargetFPS > 0 && s.TargetFPS < fps:
+		result.frameMs, result.intervalMs = 1000/fps, 1000/s.TargetFPS
+	case s.IntervalMs > 0:
+		result.frameMs, result.intervalMs = 1000/fps, s.IntervalMs
+	}
+	return result
+}
+
+// periodMs returns average time between analyzed frames, so every analyzed frame stands for this part of video. It is
+// 0 if fps isn't known
+func (s Sampling) periodMs(fps float64) float64 {
+	if fps <= 0 {
+		return 0
+	}
+	frameMs := 1000 / fps
+	switch {
+	case s.Stride > 1:
+		return float64(s.Stride) * frameMs
+	case s.TargetFPS > 0 && s.TargetFPS < fps:
+		return 1000 / s.TargetFPS
+	case s.IntervalMs > frameMs:
+		return s.IntervalMs
+	}
+	return frameMs
+}
+
+// returns index of interval, which contains frame. Frame starting exactly at bound of interval belongs to the next one
+// despite rounding errors
+func (s sampler) interval(frame int64) int64 {
+	return int64(math.Floor(float64(frame)*s.frameMs/s.intervalMs + 1e-9))
+}
+
+// analyze returns whether frame is analyzed: every stride-th frame or the first frame of every interval
+func (s sampler) analyze(frame int64) bool {
+	if s.intervalMs == 0 {
+		return frame%s.stride == 0
+	}
+	return frame == 0 || s.interval(frame) != s.interval(frame-1)
+}
+
+// skip returns amount of frames from given one to the next analyzed frame
+func (s sampler) skip(frame int64) int {
+	if s.intervalMs == 0 {
+		return int((s.stride - frame%s.stride) % s.stride)
+	}
+	skipped := 0
+	for ; !s.analyze(frame); frame++ {
+		skipped++
+	}
+	return skipped
+}
@@ -0,0 +1,143 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSamplerPicks(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampling Sampling
+		fps      float64
+		//analyzed frames among the first 12
+		want []int64
+	}{
+		{"every frame", Sampling{}, 30, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"stride 1", Sampling{Stride: 1}, 30, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"stride 5", Sampling{Stride: 5}, 30, []int64{0, 5, 10}},
+		{"stride without fps", Sampling{Stride: 4}, 0, []int64{0, 4, 8}},
+		{"target fps", Sampling{TargetFPS: 10}, 30, []int64{0, 3, 6, 9}},
+		//frames are taken from every interval of 1/4 second, so distance between them is 7 or 8 frames
+		{"fractional target fps", Sampling{TargetFPS: 4}, 30, []int64{0, 8}},
+		{"uneven target fps", Sampling{TargetFPS: 20}, 30, []int64{0, 2, 3, 5, 6, 8, 9, 11}},
+		{"target fps above video fps", Sampling{TargetFPS: 60}, 30, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"target fps equal to video fps", Sampling{TargetFPS: 25}, 25, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"interval", Sampling{IntervalMs: 200}, 25, []int64{0, 5, 10}},
+		{"interval between frames", Sampling{IntervalMs: 100}, 30, []int64{0, 3, 6, 9}},
+		{"interval shorter than frame", Sampling{IntervalMs: 10}, 30, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		//time based sampling needs fps of video
+		{"target fps without fps", Sampling{TargetFPS: 10}, 0, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"interval without fps", Sampling{IntervalMs: 200}, 0, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newSampler(test.sampling, test.fps)
+			var got []int64
+			for frame := int64(0); frame < 12; frame++ {
+				if s.analyze(frame) {
+					got = append(got, frame)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("analyzed frames %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// skip leads to the next analyzed frame, so reading of video may jump over skipped frames
+func TestSamplerSkip(t *testing.T) {
+	for _, sampling := range []Sampling{{}, {Stride: 3}, {TargetFPS: 7}, {IntervalMs: 90}, {IntervalMs: 5}} {
+		s := newSampler(sampling, 29.97)
+		for frame := int64(0); frame < 300; frame++ {
+			skip := s.skip(frame)
+			for i := int64(0); i < int64(skip); i++ {
+				if s.analyze(frame + i) {
+					t.Fatalf("%+v: skip(%d) = %d jumps over analyzed frame %d", sampling, frame, skip, frame+i)
+				}
+			}
+			if !s.analyze(frame + int64(skip)) {
+				t.Fatalf("%+v: skip(%d) = %d doesn't lead to analyzed frame", sampling, frame, skip)
+			}
+		}
+	}
+}
+
+// long video gets the same amount of analyzed frames as requested rate gives
+func TestSamplerRate(t *testing.T) {
+	tests := []struct {
+		sampling Sampling
+		fps      float64
+		want     int
+	}{
+		{Sampling{TargetFPS: 10}, 29.97, 600},
+		{Sampling{TargetFPS: 1}, 25, 60},
+		{Sampling{IntervalMs: 500}, 30, 120},
+		{Sampling{IntervalMs: 333}, 60, 181},
+	}
+	for _, test := range tests {
+		s := newSampler(test.sampling, test.fps)
+		//one minute of video
+		frames := int64(60 * test.fps)
+		analyzed := 0
+		for frame := int64(0); frame < frames; frame++ {
+			if s.analyze(frame) {
+				analyzed++
+			}
+		}
+		if analyzed < test.want-1 || analyzed > test.want+1 {
+			t.Errorf("%+v of %g fps analyzed %d frames of minute, want %d", test.sampling, test.fps, analyzed, test.want)
+		}
+	}
+}
+
+func TestSamplingPeriod(t *testing.T) {
+	tests := []struct {
+		sampling Sampling
+		fps      float64
+		want     float64
+	}{
+		{Sampling{}, 25, 40},
+		{Sampling{Stride: 5}, 25, 200},
+		{Sampling{TargetFPS: 10}, 30, 100},
+		{Sampling{TargetFPS: 60}, 25, 40},
+		{Sampling{IntervalMs: 500}, 25, 500},
+		{Sampling{IntervalMs: 10}, 25, 40},
+		{Sampling{Stride: 5}, 0, 0},
+	}
+	for _, test := range tests {
+		if got := test.sampling.periodMs(test.fps); got != test.want {
+			t.Errorf("%+v of %g fps has period %g, want %g", test.sampling, test.fps, got, test.want)
+		}
+	}
+}
+
+func TestSamplingValidate(t *testing.T) {
+	tests := []struct {
+		sampling Sampling
+		valid    bool
+	}{
+		{Sampling{}, true},
+		{Sampling{Stride: 2}, true},
+		{Sampling{TargetFPS: 0.5}, true},
+		{Sampling{IntervalMs: 1000}, true},
+		{Sampling{Stride: -1}, false},
+		{Sampling{TargetFPS: -5}, false},
+		{Sampling{IntervalMs: -1}, false},
+		{Sampling{Stride: 2, TargetFPS: 10}, false},
+		{Sampling{Stride: 2, IntervalMs: 100}, false},
+		{Sampling{TargetFPS: 10, IntervalMs: 100}, false},
+		{Sampling{Stride: 2, TargetFPS: 10, IntervalMs: 100}, false},
+	}
+	for _, test := range tests {
+		err := test.sampling.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%+v was rejected: %v", test.sampling, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidSampling) {
+			t.Errorf("%+v: Validate returned %v, want ErrInvalidSampling", test.sampling, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package recognizer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrIllegalTransition is returned when video can't be moved from its current status to requested one
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// Allowed transitions of video status. Error, Canceled and Successful are final statuses, video can't leave them
+var transitions = map[VideoStatus][]VideoStatus{
+	InQueue:    {Processing, Paused, Canceled, Error},
+	Processing: {InQueue, Paused, Canceled, Error, Successful},
+	Paused:     {InQueue, Processing, Canceled},
+}
+
+var statusNames = map[VideoStatus]string{
+	InQueue:    "queue",
+	Processing: "processing",
+	Error:      "error",
+	Canceled:   "canceled",
+	Successful: "successful",
+	Paused:     "paused",
+}
+
+func (s VideoStatus) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("status(%d)", int(s))
+}
+
+// IsFinal reports whether video in this status won't be processed anymore
+func (s VideoStatus) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransition reports whether video can be moved from status s to status to
+func (s VideoStatus) CanTransition(to VideoStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns error wrapping ErrIllegalTransition if video can't be moved from status from to status to
+func checkTransition(from, to VideoStatus) error {
+	if from != to && !from.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// ParseVideoStatus accepts status as number or as its name
+func ParseVideoStatus(s string) (VideoStatus, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if _, ok := statusNames[VideoStatus(n)]; ok {
+			return VideoStatus(n), nil
+		}
+	}
+	for status, name := range statusNames {
+		if strings.EqualFold(name, s) {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown video status %q", s)
+}
@@ -0,0 +1,211 @@
+package recognizer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrVideoNotFound = errors.New("unable to find video with given id")
+
+// JobStore keeps state of every submitted video. Implementations must be safe for concurrent use,
+// because videos are updated by worker goroutines and read by http handlers at the same time
+type JobStore interface {
+	// Get returns video with given id or ErrVideoNotFound
+	Get(id int32) (Video, error)
+	// List returns all known videos ordered by id
+	List() []Video
+	// Update applies fn to stored video and saves result, video is created if there is no video with given id yet.
+	// If fn changes status of existing video to one, which is not allowed by state machine, nothing is saved
+	// and error wrapping ErrIllegalTransition is returned. Every status change is recorded to history
+	Update(id int32, fn func(video *Video)) (Video, error)
+	// Watch returns channel which receives every saved video until ctx is done
+	Watch(ctx context.Context) <-chan Video
+	// History returns every status, which video with given id had, from the oldest to the newest
+	History(id int32) ([]StatusChange, error)
+	// SaveDetections replaces detections of every given frame of video in one write, so frame processed twice after
+	// restart isn't duplicated
+	SaveDetections(id int32, frames map[int64][]FrameDetection) error
+	// Detections returns detections of video matching filter ordered by frame
+	Detections(id int32, filter DetectionFilter) ([]FrameDetection, error)
+	// Close releases resources held by store
+	Close() error
+}
+
+// StatusChange is one record of video status history
+type StatusChange struct {
+	From   VideoStatus `json:"from"`
+	Status VideoStatus `json:"video_status"`
+	At     time.Time   `json:"at"`
+	Reason string      `json:"reason"`
+}
+
+// stamp sets timestamps of video, they are owned by store, so values set by fn in Update are replaced with stored ones
+func stamp(video *Video, stored Video, created bool, now time.Time) {
+	video.CreatedAt, video.StartedAt, video.FinishedAt = stored.CreatedAt, stored.StartedAt, stored.FinishedAt
+	if created {
+		video.CreatedAt = now
+	}
+	video.UpdatedAt = now
+	if video.Status == Processing && video.StartedAt == nil {
+		video.StartedAt = &now
+	}
+	if video.Status.IsFinal() && video.FinishedAt == nil {
+		video.FinishedAt = &now
+	}
+}
+
+// returns history record for video, which was in status from before update
+func newStatusChange(from VideoStatus, video Video) StatusChange {
+	return StatusChange{From: from, Status: video.Status, At: time.Now(), Reason: video.Reason}
+}
+
+// size of buffer of every watcher, updates without status change are dropped for watchers which are not able to keep up
+const watchBuffer = 64
+
+// watchers is a set of channels returned by JobStore.Watch, it is shared by store implementations
+type watchers struct {
+	mu        sync.Mutex
+	mailboxes map[*mailbox[Video]]struct{}
+}
+
+func (w *watchers) watch(ctx context.Context) <-chan Video {
+	mb := newMailbox[Video](watchBuffer)
+	w.mu.Lock()
+	if w.mailboxes == nil {
+		w.mailboxes = make(map[*mailbox[Video]]struct{})
+	}
+	w.mailboxes[mb] = struct{}{}
+	w.mu.Unlock()
+	go func() {
+		mb.run(ctx)
+		w.mu.Lock()
+		delete(w.mailboxes, mb)
+		w.mu.Unlock()
+	}()
+	return mb.ch
+}
+
+// notify sends video to every watcher without blocking, update changing status is never dropped
+func (w *watchers) notify(video Video, statusChanged bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for mb := range w.mailboxes {
+		mb.push(video, statusChanged)
+	}
+}
+
+// MemoryStore is JobStore, which keeps videos in map guarded by mutex. Nothing survives restart, so service uses
+// BoltStore, and MemoryStore is the reference implementation of contract of JobStore in tests
+type MemoryStore struct {
+	mu         sync.RWMutex
+	videos     map[int32]Video
+	history    map[int32][]StatusChange
+	detections map[int32]map[int64][]FrameDetection
+	watchers   watchers
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		videos:     make(map[int32]Video),
+		history:    make(map[int32][]StatusChange),
+		detections: make(map[int32]map[int64][]FrameDetection)}
+}
+
+func (s *MemoryStore) Get(id int32) (Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	video, ok := s.videos[id]
+	if !ok {
+		return Video{}, ErrVideoNotFound
+	}
+	return video, nil
+}
+
+func (s *MemoryStore) List() []Video {
+	s.mu.RLock()
+	videos := make([]Video, 0, len(s.videos))
+	for _, video := range s.videos {
+		videos = append(videos, video)
+	}
+	s.mu.RUnlock()
+	sort.Slice(videos, func(i, j int) bool { return videos[i].Id < videos[j].Id })
+	return videos
+}
+
+func (s *MemoryStore) Update(id int32, fn func(video *Video)) (Video, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	video, ok := s.videos[id]
+	if !ok {
+		video = Video{Id: id}
+	}
+	stored := video
+	fn(&video)
+	video.Id = id
+	previous := stored.Status
+	if !ok {
+		previous = video.Status
+	} else if err := checkTransition(previous, video.Status); err != nil {
+		return Video{}, err
+	}
+	stamp(&video, stored, !ok, time.Now())
+	s.videos[id] = video
+	statusChanged := !ok || previous != video.Status
+	if statusChanged {
+		s.history[id] = append(s.history[id], newStatusChange(previous, video))
+	}
+	s.watchers.notify(video, statusChanged)
+	return video, nil
+}
+
+func (s *MemoryStore) Watch(ctx context.Context) <-chan Video {
+	return s.watchers.watch(ctx)
+}
+
+func (s *MemoryStore) History(id int32) ([]StatusChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history, ok := s.history[id]
+	if !ok {
+		return nil, ErrVideoNotFound
+	}
+	return append([]StatusChange(nil), history...), nil
+}
+
+func (s *MemoryStore) SaveDetections(id int32, frames map[int64][]FrameDetection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detections[id] == nil {
+		s.detections[id] = make(map[int64][]FrameDetection)
+	}
+	for frame, detections := range frames {
+		s.detections[id][frame] = append([]FrameDetection(nil), detections...)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Detections(id int32, filter DetectionFilter) ([]FrameDetection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	frames := make([]int64, 0, len(s.detections[id]))
+	for frame := range s.detections[id] {
+		frames = append(frames, frame)
+	}
+	sort.Slice(frames, func(i, j int) bool { return frames[i] < frames[j] })
+	var detections []FrameDetection
+	for _, frame := range frames {
+		for _, detection := range s.detections[id][frame] {
+			if filter.matches(detection) {
+				detections = append(detections, detection)
+			}
+		}
+	}
+	return detections, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
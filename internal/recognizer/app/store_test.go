@@ -0,0 +1,319 @@
+package recognizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MemoryStore is reference implementation of JobStore, every test of contract is run against both stores
+func forEachStore(t *testing.T, test func(t *testing.T, s JobStore)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		s, err := OpenBoltStore(filepath.Join(t.TempDir(), "jobs.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+}
+
+func setStatus(status VideoStatus, reason string) func(video *Video) {
+	return func(video *Video) {
+		video.Status, video.Reason = status, reason
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		if _, err := s.Get(1); !errors.Is(err, ErrVideoNotFound) {
+			t.Fatalf("Get of unknown video returned %v, want ErrVideoNotFound", err)
+		}
+		for _, id := range []int32{2, 1} {
+			if _, err := s.Update(id, func(video *Video) { video.Name = "video.mp4" }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		video, err := s.Update(1, func(video *Video) {
+			video.Id = 5
+			video.Status = Processing
+			video.Frame = 10
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.Id != 1 || video.Name != "video.mp4" || video.Frame != 10 {
+			t.Errorf("Update returned %+v", video)
+		}
+		if video.CreatedAt.IsZero() || video.StartedAt == nil || video.FinishedAt != nil {
+			t.Errorf("wrong timestamps of processing video: %+v", video)
+		}
+		stored, err := s.Get(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Frame != 10 || stored.Status != Processing {
+			t.Errorf("Get returned %+v", stored)
+		}
+		finished, err := s.Update(1, setStatus(Successful, "done"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if finished.FinishedAt == nil || !finished.StartedAt.Equal(*video.StartedAt) {
+			t.Errorf("wrong timestamps of finished video: %+v", finished)
+		}
+		videos := s.List()
+		if len(videos) != 2 || videos[0].Id != 1 || videos[1].Id != 2 {
+			t.Errorf("List returned %+v", videos)
+		}
+	})
+}
+
+func TestStoreTransitions(t *testing.T) {
+	tests := []struct {
+		from, to VideoStatus
+		allowed  bool
+	}{
+		{InQueue, Processing, true},
+		{InQueue, Paused, true},
+		{InQueue, Successful, false},
+		{Processing, InQueue, true},
+		{Processing, Successful, true},
+		{Paused, Processing, true},
+		{Paused, Successful, false},
+		{Successful, Processing, false},
+		{Canceled, InQueue, false},
+		{Error, Paused, false},
+		{Successful, Successful, true},
+	}
+	for _, test := range tests {
+		t.Run(test.from.String()+"-"+test.to.String(), func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s JobStore) {
+				if _, err := s.Update(1, setStatus(test.from, "created")); err != nil {
+					t.Fatal(err)
+				}
+				_, err := s.Update(1, func(video *Video) {
+					video.Status = test.to
+					video.Frame = 10
+				})
+				if test.allowed {
+					if err != nil {
+						t.Fatalf("transition was rejected: %v", err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrIllegalTransition) {
+					t.Fatalf("Update returned %v, want ErrIllegalTransition", err)
+				}
+				video, err := s.Get(1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if video.Status != test.from || video.Frame != 0 {
+					t.Errorf("rejected update was saved: %+v", video)
+				}
+			})
+		})
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		if _, err := s.History(1); !errors.Is(err, ErrVideoNotFound) {
+			t.Fatalf("History of unknown video returned %v, want ErrVideoNotFound", err)
+		}
+		updates := []func(video *Video){
+			setStatus(InQueue, "submitted"),
+			setStatus(Processing, "started"),
+			func(video *Video) { video.Frame = 100 },
+			setStatus(Paused, "paused"),
+			setStatus(Processing, "resumed"),
+			setStatus(Canceled, "canceled"),
+			//rejected update isn't recorded
+			setStatus(Processing, "restarted"),
+		}
+		for _, update := range updates {
+			s.Update(1, update)
+		}
+		history, err := s.History(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []StatusChange{
+			{From: InQueue, Status: InQueue, Reason: "submitted"},
+			{From: InQueue, Status: Processing, Reason: "started"},
+			{From: Processing, Status: Paused, Reason: "paused"},
+			{From: Paused, Status: Processing, Reason: "resumed"},
+			{From: Processing, Status: Canceled, Reason: "canceled"},
+		}
+		if len(history) != len(want) {
+			t.Fatalf("history has %d records, want %d: %+v", len(history), len(want), history)
+		}
+		for i := range want {
+			if history[i].From != want[i].From || history[i].Status != want[i].Status || history[i].Reason != want[i].Reason {
+				t.Errorf("history[%d] = %+v, want %+v", i, history[i], want[i])
+			}
+			if i > 0 && history[i].At.Before(history[i-1].At) {
+				t.Errorf("history[%d] is older than previous record", i)
+			}
+		}
+	})
+}
+
+func TestStoreWatch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		ctx, cancel := context.WithCancel(context.Background())
+		updates := s.Watch(ctx)
+		for _, status := range []VideoStatus{InQueue, Processing} {
+			if _, err := s.Update(1, setStatus(status, "")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, want := range []VideoStatus{InQueue, Processing} {
+			select {
+			case video := <-updates:
+				if video.Id != 1 || video.Status != want {
+					t.Errorf("Watch sent %+v, want status %s", video, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("update wasn't sent to watcher")
+			}
+		}
+		cancel()
+		select {
+		case _, ok := <-updates:
+			if ok {
+				t.Error("watcher got update after unsubscribe")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel of watcher wasn't closed after unsubscribe")
+		}
+		//store keeps working after watcher is gone
+		if _, err := s.Update(1, setStatus(Successful, "")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStoreDetections(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		s.SaveDetections(1, map[int64][]FrameDetection{
+			300: {{Frame: 300, TimestampMs: 10000, Person: "bob", Matched: true}},
+			2:   {{Frame: 2, TimestampMs: 80, Person: "alice"}}})
+		s.SaveDetections(2, map[int64][]FrameDetection{1: {{Frame: 1, Person: "alice"}}})
+		//frame processed again after restart replaces its detections, other frames are kept
+		s.SaveDetections(1, map[int64][]FrameDetection{2: {
+			{Frame: 2, TimestampMs: 80, Person: "Alice", Matched: true},
+			{Frame: 2, TimestampMs: 80, Person: "bob", Confidence: 0.5}}})
+		s.SaveDetections(1, nil)
+
+		detections, err := s.Detections(1, DetectionFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var frames []int64
+		for _, detection := range detections {
+			frames = append(frames, detection.Frame)
+		}
+		if len(frames) != 3 || frames[0] != 2 || frames[1] != 2 || frames[2] != 300 {
+			t.Errorf("Detections returned frames %v, want [2 2 300]", frames)
+		}
+		filtered, err := s.Detections(1, DetectionFilter{Person: "alice", MatchedOnly: true, ToMs: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(filtered) != 1 || filtered[0].Person != "Alice" {
+			t.Errorf("filtered detections: %+v", filtered)
+		}
+	})
+}
+
+// run with -race: updates from workers and reads from handlers happen at the same time
+func TestStoreConcurrentUse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		const workers, updates = 4, 50
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		watched := s.Watch(ctx)
+		go func() {
+			for range watched {
+			}
+		}()
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(2)
+			go func(id int32) {
+				defer wg.Done()
+				s.Update(id, setStatus(Processing, "started"))
+				for i := 0; i < updates; i++ {
+					s.Update(id, func(video *Video) { video.Frame++ })
+					s.SaveDetections(id, map[int64][]FrameDetection{int64(i): {{Frame: int64(i)}}})
+				}
+			}(int32(w + 1))
+			go func(id int32) {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					s.Get(id)
+					s.List()
+					s.History(id)
+					s.Detections(id, DetectionFilter{})
+				}
+			}(int32(w + 1))
+		}
+		wg.Wait()
+		for id := int32(1); id <= workers; id++ {
+			video, err := s.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if video.Frame != updates {
+				t.Errorf("video %d has frame %d, want %d", id, video.Frame, updates)
+			}
+		}
+	})
+}
+
+// watcher, which doesn't read, loses progress updates, but gets every status change
+func TestStoreWatchKeepsStatusChanges(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s JobStore) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		updates := s.Watch(ctx)
+		s.Update(1, setStatus(InQueue, ""))
+		s.Update(1, setStatus(Processing, ""))
+		for i := 0; i < 4*watchBuffer; i++ {
+			s.Update(1, func(video *Video) { video.Frame++ })
+		}
+		s.Update(1, setStatus(Paused, ""))
+		s.Update(1, setStatus(Processing, ""))
+		s.Update(1, setStatus(Successful, ""))
+
+		var statuses []VideoStatus
+		received := 0
+		for len(statuses) == 0 || statuses[len(statuses)-1] != Successful {
+			select {
+			case video := <-updates:
+				received++
+				if len(statuses) == 0 || statuses[len(statuses)-1] != video.Status {
+					statuses = append(statuses, video.Status)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("final status wasn't sent, got statuses %v", statuses)
+			}
+		}
+		want := []VideoStatus{InQueue, Processing, Paused, Processing, Successful}
+		if fmt.Sprint(statuses) != fmt.Sprint(want) {
+			t.Errorf("watcher got statuses %v, want %v", statuses, want)
+		}
+		if received > watchBuffer+len(want) {
+			t.Errorf("watcher got %d updates, progress updates should be dropped", received)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package recognizer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Default parameters of timeline aggregation
+const (
+	// matches of one person separated by smaller gap are merged into one appearance
+	DefaultTimelineGapMs = 2000.0
+	// appearances shorter than this are dropped
+	DefaultTimelineMinDurationMs = 0.0
+)
+
+// Appearance is an interval of video, where person is continuously seen
+type Appearance struct {
+	Person  string  `json:"person"`
+	StartMs float64 `json:"start_ms"`
+	EndMs   float64 `json:"end_ms"`
+	//StartMs and EndMs in hh:mm:ss format
+	Start string `json:"start"`
+	End   string `json:"end"`
+	//amount of matched detections inside interval
+	Detections int `json:"detections"`
+	//the smallest distance to person inside interval
+	BestDistance float64 `json:"best_distance"`
+}
+
+// Timeline is a result of aggregation of matched detections of video
+type Timeline struct {
+	//appearances ordered by start
+	Appearances []Appearance `json:"appearances"`
+	//sum of durations of appearances of every person
+	ScreenTimeMs map[string]float64 `json:"screen_time_ms"`
+}
+
+// BuildTimeline merges consecutive matches of the same person into appearance intervals. Every match lasts stepMs, it is
+// time between analyzed frames, so appearance of single match isn't empty. Matches separated by not more than gapMs
+// are merged, appearances shorter than minDurationMs are dropped. Detections should be ordered by frame
+func BuildTimeline(detections []FrameDetection, stepMs, gapMs, minDurationMs float64) Timeline {
+	timeline := Timeline{Appearances: []Appearance{}, ScreenTimeMs: make(map[string]float64)}
+	//appearance of every person, which is not closed yet
+	open := make(map[string]*Appearance)
+	closeAppearance := func(appearance *Appearance) {
+		if appearance.EndMs-appearance.StartMs < minDurationMs {
+			return
+		}
+		appearance.Start, appearance.End = formatTimestamp(appearance.StartMs), formatTimestamp(appearance.EndMs)
+		timeline.Appearances = append(timeline.Appearances, *appearance)
+		timeline.ScreenTimeMs[appearance.Person] += appearance.EndMs - appearance.StartMs
+	}
+
+	for _, detection := range detections {
+		if !detection.Matched {
+			continue
+		}
+		appearance, ok := open[detection.Person]
+		if ok && detection.TimestampMs-appearance.EndMs > gapMs {
+			closeAppearance(appearance)
+			ok = false
+		}
+		if !ok {
+			appearance = &Appearance{
+				Person:       detection.Person,
+				StartMs:      detection.TimestampMs,
+				BestDistance: detection.Distance}
+			open[detection.Person] = appearance
+		}
+		appearance.EndMs = max(appearance.EndMs, detection.TimestampMs+stepMs)
+		appearance.Detections++
+		if detection.Distance < appearance.BestDistance {
+			appearance.BestDistance = detection.Distance
+		}
+	}
+	for _, appearance := range open {
+		closeAppearance(appearance)
+	}
+
+	sort.Slice(timeline.Appearances, func(i, j int) bool {
+		a, b := timeline.Appearances[i], timeline.Appearances[j]
+		if a.StartMs != b.StartMs {
+			return a.StartMs < b.StartMs
+		}
+		return a.Person < b.Person
+	})
+	return timeline
+}
+
+func formatTimestamp(ms float64) string {
+	seconds := int64(ms / 1000)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// GetTimeline returns appearances of persons on video with given id, which are built from its matched detections.
+// Every match lasts until the next analyzed frame
+func (vP *VideoProcessor) GetTimeline(id int32, gapMs, minDurationMs float64) (Timeline, error) {
+	video, err := vP.GetVideo(id)
+	if err != nil {
+		return Timeline{}, err
+	}
+	detections, err := vP.store.Detections(id, DetectionFilter{MatchedOnly: true})
+	if err != nil {
+		return Timeline{}, err
+	}
+	return BuildTimeline(detections, video.Options.Sampling.periodMs(video.FPS), gapMs, minDurationMs), nil
+}
@@ -0,0 +1,92 @@
+package recognizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// returns matched detection of person at given time
+func match(person string, ms, distance float64) FrameDetection {
+	return FrameDetection{Person: person, TimestampMs: ms, Distance: distance, Matched: true}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	tests := []struct {
+		name          string
+		detections    []FrameDetection
+		stepMs        float64
+		gapMs         float64
+		minDurationMs float64
+		want          []Appearance
+		screenTime    map[string]float64
+	}{
+		{name: "no detections", stepMs: 40, gapMs: 2000,
+			want: []Appearance{}, screenTime: map[string]float64{}},
+		{name: "single match lasts one step", detections: []FrameDetection{match("alice", 1000, 0.3)}, stepMs: 40, gapMs: 2000,
+			want:       []Appearance{{Person: "alice", StartMs: 1000, EndMs: 1040, Detections: 1, BestDistance: 0.3}},
+			screenTime: map[string]float64{"alice": 40}},
+		{name: "matches within gap are merged",
+			detections: []FrameDetection{match("alice", 0, 0.4), match("alice", 1000, 0.2), match("alice", 3000, 0.3)},
+			stepMs:     1000, gapMs: 1000,
+			want:       []Appearance{{Person: "alice", StartMs: 0, EndMs: 4000, Detections: 3, BestDistance: 0.2}},
+			screenTime: map[string]float64{"alice": 4000}},
+		{name: "gap splits appearances",
+			detections: []FrameDetection{match("alice", 0, 0.4), match("alice", 40, 0.4), match("alice", 5000, 0.3)},
+			stepMs:     40, gapMs: 2000,
+			want: []Appearance{
+				{Person: "alice", StartMs: 0, EndMs: 80, Detections: 2, BestDistance: 0.4},
+				{Person: "alice", StartMs: 5000, EndMs: 5040, Detections: 1, BestDistance: 0.3}},
+			screenTime: map[string]float64{"alice": 120}},
+		{name: "persons are merged separately",
+			detections: []FrameDetection{match("bob", 0, 0.4), match("alice", 500, 0.3), match("bob", 1000, 0.2),
+				match("alice", 4000, 0.3)},
+			stepMs: 500, gapMs: 1000,
+			want: []Appearance{
+				{Person: "bob", StartMs: 0, EndMs: 1500, Detections: 2, BestDistance: 0.2},
+				{Person: "alice", StartMs: 500, EndMs: 1000, Detections: 1, BestDistance: 0.3},
+				{Person: "alice", StartMs: 4000, EndMs: 4500, Detections: 1, BestDistance: 0.3}},
+			screenTime: map[string]float64{"alice": 1000, "bob": 1500}},
+		{name: "unmatched detections are ignored",
+			detections: []FrameDetection{match("alice", 0, 0.3), {Person: "alice", TimestampMs: 1000, Distance: 0.6},
+				match("alice", 2500, 0.3)},
+			stepMs: 100, gapMs: 2500,
+			want:       []Appearance{{Person: "alice", StartMs: 0, EndMs: 2600, Detections: 2, BestDistance: 0.3}},
+			screenTime: map[string]float64{"alice": 2600}},
+		{name: "short appearances are dropped",
+			detections: []FrameDetection{match("alice", 0, 0.3), match("bob", 0, 0.3), match("bob", 1000, 0.3),
+				match("alice", 9000, 0.3)},
+			stepMs: 1000, gapMs: 2000, minDurationMs: 1500,
+			want:       []Appearance{{Person: "bob", StartMs: 0, EndMs: 2000, Detections: 2, BestDistance: 0.3}},
+			screenTime: map[string]float64{"bob": 2000}},
+		{name: "single match passes min duration of one step",
+			detections: []FrameDetection{match("alice", 0, 0.3)}, stepMs: 1000, gapMs: 2000, minDurationMs: 1000,
+			want:       []Appearance{{Person: "alice", StartMs: 0, EndMs: 1000, Detections: 1, BestDistance: 0.3}},
+			screenTime: map[string]float64{"alice": 1000}},
+		{name: "several faces of one person on frame",
+			detections: []FrameDetection{match("alice", 0, 0.3), match("alice", 0, 0.1)}, stepMs: 40, gapMs: 0,
+			want:       []Appearance{{Person: "alice", StartMs: 0, EndMs: 40, Detections: 2, BestDistance: 0.1}},
+			screenTime: map[string]float64{"alice": 40}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeline := BuildTimeline(test.detections, test.stepMs, test.gapMs, test.minDurationMs)
+			for i := range timeline.Appearances {
+				timeline.Appearances[i].Start, timeline.Appearances[i].End = "", ""
+			}
+			if !reflect.DeepEqual(timeline.Appearances, test.want) {
+				t.Errorf("appearances %+v, want %+v", timeline.Appearances, test.want)
+			}
+			if !reflect.DeepEqual(timeline.ScreenTimeMs, test.screenTime) {
+				t.Errorf("screen time %v, want %v", timeline.ScreenTimeMs, test.screenTime)
+			}
+		})
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	for ms, want := range map[float64]string{0: "00:00:00", 999: "00:00:00", 72500: "00:01:12", 3723000: "01:02:03"} {
+		if got := formatTimestamp(ms); got != want {
+			t.Errorf("formatTimestamp(%g) = %s, want %s", ms, got, want)
+		}
+	}
+}
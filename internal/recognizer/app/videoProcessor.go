@@ -4,37 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"math"
 	"os"
 	"path"
+	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gocv.io/x/gocv"
 
+	"go_cv_test/internal/config"
 	face "go_cv_test/internal/recognizer"
 )
 
-// Путь до папки с моделями. Папка должна содержать следующий файлы: dlib_face_recognition_resnet_model_v1.dat,
-// mmod_human_face_detector.dat, shape_predictor_68_face_landmarks.dat. Архивы с этими файлами можно скачать из
-// https://github.com/davisking/dlib-models.
-const modelsPath = "./internal/recognizer/app/models"
-
-// Путь до папки с персонами. Папка должна содержать на первом уровне папки, где название папки ― имя персоны,
-// а на втором уровне ― файлы с фотографиями лица соответствующей персоны.
-const personsPath = "./internal/recognizer/app/persons"
-
 // ID оборудования для получения видеопотока. В нашем случае 0 ― это ID стандартной веб-камеры.
 const deviceID = 0
 
-// Параметры векторизации, которые влияют на качество получаемого вектора:
-const (
-	padding   = 0.2 // насколько увеличивать квадрат выявленного лица;
-	jittering = 30  // кол-во генерируемых немного сдвинутых и повёрнутых копий лица.
-)
-
 // Синий цвет.
 var blue = color.RGBA{
 	R: 0,
@@ -43,9 +32,6 @@ var blue = color.RGBA{
 	A: 0,
 }
 
-// Минимальное расстояние соответствия персоне.
-const matchDistance = 0.5
-
 // Структура, описывающая персону.
 type Person struct {
 	// Имя персоны.
@@ -76,6 +62,61 @@ type Video struct {
 	Status     VideoStatus `json:"video_status"`
 	Percentage float64     `json:"percentage"`
 	Name       string      `json:"name"`
+	//path to uploaded file, it is needed for restarting job after restart of the service
+	File string `json:"file"`
+	//amount of already processed frames, processing is continued from this frame after restart
+	Frame int64 `json:"frame"`
+	//reason of the last status change
+	Reason  string     `json:"reason"`
+	Options JobOptions `json:"options"`
+	//failure, which moved video to Error
+	Error *JobError `json:"error,omitempty"`
+	//amount of frames skipped because of failures and the last of them, see JobOptions.OnError
+	SkippedFrames int64     `json:"skipped_frames,omitempty"`
+	LastSkipped   *JobError `json:"last_skipped,omitempty"`
+	//amount of sampled frames, which didn't differ enough from the last analyzed frame, see JobOptions.Gating
+	GatedFrames int64 `json:"gated_frames,omitempty"`
+	//frame rate of video, it is known after start of processing
+	FPS float64 `json:"fps,omitempty"`
+	//path to annotated copy of video, it is set when annotated output is requested
+	Annotated string `json:"annotated,omitempty"`
+	//timestamps are set by JobStore
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	//time of the first start of processing
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	//time of getting final status
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Режимы использования галереи задачей.
+const (
+	// задача переходит на новый снимок галереи на границе кадров.
+	GalleryLive = "live"
+	// задача до конца использует снимок, взятый при старте обработки, после перезапуска сервиса берётся новый снимок.
+	GalleryPinned = "pinned"
+)
+
+// JobOptions are given on upload and kept with video
+type JobOptions struct {
+	//write copy of video with boxes and names of found persons
+	Annotate bool `json:"annotate"`
+	//one of Gallery* constants, empty means GalleryLive
+	Gallery string `json:"gallery,omitempty"`
+	//thresholds of recognition of metric of job, which override global ones of this metric
+	Thresholds Thresholds `json:"thresholds"`
+	//amount of the closest persons kept for every face, 0 means global amount
+	Candidates int `json:"candidates,omitempty"`
+	//one of ErrorPolicy* constants, empty means ErrorPolicyFailFast
+	OnError string `json:"on_error,omitempty"`
+	//frames, which are analyzed, zero value means every frame
+	Sampling Sampling `json:"sampling"`
+	//sampled frames, which are similar to the last analyzed frame, aren't analyzed, zero value disables gating
+	Gating Gating `json:"gating"`
+	//one of Metric* constants, empty means MetricEuclidean
+	Metric string `json:"metric,omitempty"`
+	//one of Aggregate* constants, empty means AggregateNearest
+	Aggregation string `json:"aggregation,omitempty"`
 }
 
 type VideoProcessor struct {
@@ -87,122 +128,370 @@ type VideoProcessor struct {
 	grCounter atomic.Int32
 	//used for generating id for each processing video
 	processId atomic.Int32
-	//stores videos
-	videos map[int32]Video
-	//used for pausing goroutines with provived video id
-	switcher chan int32
-	//used for runVideoUpdater(), this is a buffered channel
-	dataBuffer chan Video
+	//stores videos, it is shared between workers and http handlers
+	store JobStore
+	//server-owned context, every job context is derived from it, so jobs outlive the request that submitted them
+	ctx context.Context
+	//handles of videos, which have running goroutine
+	jobs map[int32]*job
+	mu   sync.Mutex
+	//delivers events of videos to subscribers
+	events *eventHub
+	//stops every job on shutdown with ErrShutdown cause
+	stop context.CancelCauseFunc
+	//running worker goroutines, Close waits for them
+	workers sync.WaitGroup
+	//dlib models shared by all workers
+	models *ModelPool
+	//known persons, jobs compare faces with its snapshots, see JobOptions.Gallery
+	gallery *Gallery
+	//global thresholds of recognition by metric and amount of candidates, jobs may override them
+	thresholds map[string]Thresholds
+	//global margin and ratio of knn aggregation
+	votes      Thresholds
+	candidates int
+	//calibrations of confidence by metric
+	calibrations map[string]Calibration
+	//descriptors of gallery images computed earlier
+	descriptors *descriptorCache
+	//settings of service
+	config config.Config
+}
+
+// ErrShutdown is a cause of jobs context, when service is stopped. Such jobs keep their status and are restored on next start
+var ErrShutdown = errors.New("service is shutting down")
+
+// ServiceHealth describes state of VideoProcessor
+type ServiceHealth struct {
+	Healthy bool       `json:"healthy"`
+	Models  PoolHealth `json:"models"`
+	//max amount of videos processed at the same time and amount of taken slots
+	Slots     int `json:"slots"`
+	BusySlots int `json:"busy_slots"`
+	//amount of videos having running goroutine, including queued and paused ones
+	Jobs int `json:"jobs"`
+	//amount of known persons
+	Persons int `json:"persons"`
 }
 
 // accepts video id and returns founded video
 func (vP *VideoProcessor) GetVideo(id int32) (Video, error) {
-	val, ok := vP.videos[id]
-	if !ok {
-		return Video{}, fmt.Errorf("unable to find video with given id")
-	}
-	return val, nil
+	return vP.store.Get(id)
 }
 
-// switches state for video with provided id
+// switches state for video with provided id: paused video is resumed, other videos are paused
 func (vP *VideoProcessor) SwitchState(id int32) error {
-	_, err := vP.GetVideo(id)
+	_, j, err := vP.lookup(id)
 	if err != nil {
-		return errors.New("unable to locate video with providen id")
+		return err
 	}
-	vP.switcher <- id
+	if j != nil && j.paused() != nil {
+		return vP.Resume(id)
+	}
+	return vP.Pause(id)
+}
+
+// Pause asks worker of video to stop at the next frame, paused worker gives its slot to other videos. Video waiting
+// for slot is moved to Paused at once. Repeated pause is accepted until worker moves video to Paused
+func (vP *VideoProcessor) Pause(id int32) error {
+	video, j, err := vP.lookup(id)
+	if err != nil {
+		return err
+	}
+	//pause is accepted, but worker hasn't moved video to Paused yet
+	if j != nil && j.paused() != nil && video.Status != Paused && !video.Status.IsFinal() {
+		return nil
+	}
+	if j == nil || j.paused() != nil || !video.Status.CanTransition(Paused) {
+		return fmt.Errorf("%w: video %d can't be paused in status %s", ErrIllegalTransition, id, video.Status)
+	}
+	j.pause()
 	return nil
 }
 
-// returns ready for work VideoProcessor,
-func GetVideoProcessor(numOfCores int) *VideoProcessor {
+// Resume continues processing of paused video from the frame it was paused on
+func (vP *VideoProcessor) Resume(id int32) error {
+	video, j, err := vP.lookup(id)
+	if err != nil {
+		return err
+	}
+	if j == nil || j.paused() == nil {
+		return fmt.Errorf("%w: video %d can't be resumed in status %s", ErrIllegalTransition, id, video.Status)
+	}
+	j.unpause()
+	return nil
+}
+
+// Cancel stops processing of video, reason is saved as reason of status change
+func (vP *VideoProcessor) Cancel(id int32, reason string) error {
+	video, j, err := vP.lookup(id)
+	if err != nil {
+		return err
+	}
+	if j == nil || !video.Status.CanTransition(Canceled) {
+		return fmt.Errorf("%w: video %d can't be canceled in status %s", ErrIllegalTransition, id, video.Status)
+	}
+	j.cancel(errors.New(reason))
+	return nil
+}
+
+// returns status history of video with given id
+func (vP *VideoProcessor) GetHistory(id int32) ([]StatusChange, error) {
+	return vP.store.History(id)
+}
+
+// GetDetections returns faces found on frames of video with given id
+func (vP *VideoProcessor) GetDetections(id int32, filter DetectionFilter) ([]FrameDetection, error) {
+	if _, err := vP.GetVideo(id); err != nil {
+		return nil, err
+	}
+	return vP.store.Detections(id, filter)
+}
+
+// Subscribe returns channel of events of video with given id, or of every video if id is 0.
+// Progress events of every video are sent not more often than once in progressInterval. Channel is closed when ctx is done
+func (vP *VideoProcessor) Subscribe(ctx context.Context, id int32, progressInterval time.Duration) (<-chan Event, error) {
+	if id != 0 {
+		if _, err := vP.GetVideo(id); err != nil {
+			return nil, err
+		}
+	}
+	return vP.events.subscribe(ctx, id, progressInterval), nil
+}
+
+// returns video and handle of its worker, handle is nil if video has no running worker
+func (vP *VideoProcessor) lookup(id int32) (Video, *job, error) {
+	video, err := vP.GetVideo(id)
+	if err != nil {
+		return Video{}, nil, err
+	}
+	vP.mu.Lock()
+	defer vP.mu.Unlock()
+	return video, vP.jobs[id], nil
+}
+
+// Health reports state of model pool and of job slots
+func (vP *VideoProcessor) Health() ServiceHealth {
+	vP.mu.Lock()
+	jobs := len(vP.jobs)
+	vP.mu.Unlock()
+	models := vP.models.Health()
+	return ServiceHealth{
+		Healthy:   models.Healthy,
+		Models:    models,
+		Slots:     cap(vP.chanel),
+		BusySlots: len(vP.chanel),
+		Jobs:      jobs,
+		Persons:   len(vP.gallery.Snapshot().Persons)}
+}
+
+// returns ready for work VideoProcessor, jobs interrupted by previous shutdown are started again
+func GetVideoProcessor(cfg config.Config) (*VideoProcessor, error) {
+	numOfCores := cfg.Recognition.Workers
 	if numOfCores < 1 {
-		numOfCores = 1
+		numOfCores = runtime.NumCPU()
+	}
+	//every worker holds models only while it processes one frame, so there is no need in more sets than workers
+	modelSets := cfg.Recognition.ModelSets
+	if modelSets < 1 {
+		modelSets = min(numOfCores, maxModelSets)
 	}
+	store, err := OpenBoltStore(cfg.Storage.Jobs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open job store: %w", err)
+	}
+	index, err := NewMatcher(cfg.Recognition.Matcher)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	ctx, stop := context.WithCancelCause(context.Background())
 	vp := VideoProcessor{
-		CPUs:       numOfCores,
-		chanel:     make(chan struct{}, numOfCores),
-		videos:     make(map[int32]Video),
-		switcher:   make(chan int32),
-		dataBuffer: make(chan Video, numOfCores)}
-	vp.runVideoUpdater()
-	return &vp
+		CPUs:         numOfCores,
+		chanel:       make(chan struct{}, numOfCores),
+		store:        store,
+		ctx:          ctx,
+		stop:         stop,
+		jobs:         make(map[int32]*job),
+		events:       newEventHub(),
+		models:       LoadModelPool(cfg.Storage.Models, modelSets, cfg.Recognition.Padding, cfg.Recognition.Jittering),
+		gallery:      newGallery(cfg.Storage.Persons, cfg.Storage.GalleryIndex, index),
+		thresholds:   metricThresholds(cfg.Recognition),
+		votes:        voteThresholds(cfg.Recognition),
+		candidates:   cfg.Recognition.Candidates,
+		calibrations: calibrations(cfg.Recognition),
+		config:       cfg}
+	if err := vp.loadGallery(); err != nil {
+		log.Printf("unable to load persons: %s", err.Error())
+	} else if err := vp.watchGallery(cfg.Storage.Persons); err != nil {
+		log.Printf("unable to watch persons, gallery is changed only by api: %s", err.Error())
+	}
+	go vp.events.run(store.Watch(vp.ctx))
+	vp.recover()
+	return &vp, nil
 }
 
-// This method is used for running goroutine that can write everything that comes from channel to a map, we update video state here
-func (vP *VideoProcessor) runVideoUpdater() {
-	go func() {
-		for {
-			select {
-			case data := <-vP.dataBuffer:
-				vP.videos[data.Id] = data
-			case id := <-vP.switcher:
-				video := vP.videos[id]
-				if video.Status == 5 {
-					video.Status = 1
-					vP.videos[id] = video
-				}
-				if video.Status == 1 {
-					video.Status = 5
-					vP.videos[id] = video
-				}
+// Close stops every job and waits for workers to save their progress, then frees models and closes store.
+// Stopped jobs keep their status, so they are restored on next start
+func (vP *VideoProcessor) Close() error {
+	vP.stop(ErrShutdown)
+	vP.workers.Wait()
+	vP.models.Close()
+	//persons changed by api are kept in index
+	if err := vP.gallery.saveIndex(); err != nil {
+		log.Printf("unable to save gallery index: %s", err.Error())
+	}
+	return vP.store.Close()
+}
+
+// computes descriptors of known persons with models from pool
+func (vP *VideoProcessor) loadGallery() error {
+	m, err := vP.models.Acquire(vP.ctx)
+	if err != nil {
+		return err
+	}
+	defer vP.models.Release(m)
+	if vP.descriptors == nil {
+		vP.descriptors, err = openDescriptorCache(vP.config.Storage.DescriptorCache, vP.models.Checksum(), vP.config.Recognition.Padding, vP.config.Recognition.Jittering)
+		if err != nil {
+			log.Printf("descriptor cache is discarded: %s", err.Error())
+		}
+	}
+	persons, problems := loadPersons(m, vP.descriptors, vP.config.Storage.Persons)
+	vP.gallery.replace(persons, problems)
+	for _, problem := range problems {
+		log.Printf("photo %s of person %s is skipped: %s", problem.Photo, problem.Person, problem.Message)
+	}
+	log.Printf("%d persons were loaded", len(persons))
+	if err := vP.gallery.saveIndex(); err != nil {
+		log.Printf("unable to save gallery index: %s", err.Error())
+	}
+	vP.descriptors.compact()
+	return vP.descriptors.save()
+}
+
+// restores jobs from store: jobs, which were in queue or in process, are queued again from their last processed frame,
+// paused jobs stay paused until they are resumed
+func (vP *VideoProcessor) recover() {
+	for _, video := range vP.store.List() {
+		if video.Id > vP.processId.Load() {
+			vP.processId.Store(video.Id)
+		}
+		switch video.Status {
+		case InQueue, Processing, Paused:
+			//annotated copy can't be continued, it is written from the first frame again, so counters of frames start over
+			if video.Options.Annotate {
+				video.Frame, video.Percentage = 0, 0
+				video.SkippedFrames, video.LastSkipped, video.GatedFrames = 0, nil, 0
 			}
+			log.Printf("restoring video %d (%s) from frame %d", video.Id, video.Name, video.Frame)
+			vP.start(video, video.Status == Paused, "restored after restart")
 		}
-	}()
+	}
 }
 
-// fileName is used for nothing, but logging file name
-func (vP *VideoProcessor) RunRecognizer(ctx context.Context, cancel context.CancelCauseFunc, videoFile, fileName string, wg *sync.WaitGroup) {
-	var id = vP.processId.Add(1)
-	vidInfo := Video{Id: id,
-		Status:     0,
-		Name:       fileName,
-		Percentage: 0.0}
-	//here we write data about video into chanel, which is read by one goroutine
-	vP.dataBuffer <- vidInfo
-	//here we signal that we want to start a new video processing, it will waint until channel will have space
-	vP.chanel <- struct{}{}
-	//video is in process
-	vidInfo.Status = 1
-	vP.dataBuffer <- vidInfo
-
-	// Инициализация детектора лиц, который будет выявлять лица.
-	detector, err := face.NewDetector(path.Join(modelsPath, "mmod_human_face_detector.dat"))
-	// Check that detecor init successful
-	if err != nil {
-		fmt.Printf("Error at detecor init stage: %s\n", err.Error())
-		vidInfo.Status = 2
-		vP.dataBuffer <- vidInfo
-		cancel(errors.New("unable to init face detector: " + err.Error()))
-		wg.Done()
-		return
+// saves state of video, which is owned by worker goroutine
+func (vP *VideoProcessor) save(vidInfo Video) {
+	if _, err := vP.store.Update(vidInfo.Id, func(video *Video) { *video = vidInfo }); err != nil {
+		log.Printf("unable to save state of video %d: %s", vidInfo.Id, err.Error())
 	}
-	defer detector.Close()
+}
 
-	// Инициализация распознавателя лиц, который будет векторизовывать лица.
-	recognizer, err := face.NewRecognizer(
-		path.Join(modelsPath, "shape_predictor_68_face_landmarks.dat"),
-		path.Join(modelsPath, "dlib_face_recognition_resnet_model_v1.dat"))
-	// Check that recognizer init successful
-	if err != nil {
-		fmt.Printf("Error at recognizer init stage %s\n", err.Error())
-		vidInfo.Status = 2
-		vP.dataBuffer <- vidInfo
-		cancel(errors.New("unable to init face recognizer: " + err.Error()))
-		wg.Done()
+// checkpoint throttles saving of progress of running video, every save is a synchronous write of store, which also
+// wakes every watcher of store
+type checkpoint struct {
+	frames   int64
+	interval time.Duration
+	//frame and time of the last save
+	frame int64
+	at    time.Time
+}
+
+func newCheckpoint(r config.Recognition, frame int64) *checkpoint {
+	return &checkpoint{frames: int64(r.CheckpointFrames), interval: time.Duration(r.CheckpointInterval), frame: frame, at: time.Now()}
+}
+
+// due returns whether progress on given frame should be saved, it remembers frame as saved if so
+func (c *checkpoint) due(frame int64) bool {
+	if frame-c.frame < c.frames && time.Since(c.at) < c.interval {
+		return false
+	}
+	c.frame, c.at = frame, time.Now()
+	return true
+}
+
+// moves video to given status and saves it, nothing is done if video already has this status
+func (vP *VideoProcessor) setStatus(vidInfo *Video, status VideoStatus, reason string) {
+	if vidInfo.Status == status {
 		return
 	}
-	defer recognizer.Close()
+	vidInfo.Status = status
+	vidInfo.Reason = reason
+	vP.save(*vidInfo)
+}
 
-	// Инициализация базы персон.
-	persons := loadPersons(detector, recognizer, personsPath)
+// NewJobId reserves id of the next job. Uploaded file is named by it before job is submitted, so files of jobs with
+// the same file name don't overwrite each other
+func (vP *VideoProcessor) NewJobId() int32 {
+	return vP.processId.Add(1)
+}
+
+// Submit registers video with id reserved by NewJobId in queue and starts its processing in background.
+// Job runs under server-owned context, so it keeps going after the client, which uploaded video, disconnects
+func (vP *VideoProcessor) Submit(id int32, videoFile, fileName string, options JobOptions) {
+	vP.start(Video{Id: id,
+		Name:       fileName,
+		File:       videoFile,
+		Options:    options,
+		Percentage: 0.0}, false, "uploaded")
+}
+
+// puts video in queue and starts goroutine for it, paused video waits for resume before taking place in queue
+func (vP *VideoProcessor) start(video Video, paused bool, reason string) {
+	video.Status = InQueue
+	if paused {
+		video.Status = Paused
+	}
+	video.Reason = reason
+	vP.save(video)
+	ctx, cancel := context.WithCancelCause(vP.ctx)
+	j := newJob(cancel, paused)
+	vP.mu.Lock()
+	vP.jobs[video.Id] = j
+	vP.mu.Unlock()
+	vP.workers.Add(1)
+	go func() {
+		defer func() {
+			vP.mu.Lock()
+			delete(vP.jobs, video.Id)
+			vP.mu.Unlock()
+			vP.workers.Done()
+		}()
+		vP.RunRecognizer(ctx, cancel, j, video)
+	}()
+}
+
+// vidInfo.Name is used for nothing, but logging file name, processing starts from vidInfo.Frame
+func (vP *VideoProcessor) RunRecognizer(ctx context.Context, cancel context.CancelCauseFunc, j *job, vidInfo Video) {
+	id, videoFile, fileName := vidInfo.Id, vidInfo.File, vidInfo.Name
+	//here we signal that we want to start a new video processing, it will waint until channel will have space
+	if !vP.takeSlot(ctx, j, &vidInfo) {
+		vP.interrupt(ctx, &vidInfo)
+		return
+	}
+	//slot is given back to the pool, when job is finished, paused job gives it back earlier
+	holdsSlot := true
+	defer func() {
+		if holdsSlot {
+			vP.releaseSlot()
+		}
+	}()
 
 	// Init video capture from file
 	video, err := gocv.VideoCaptureFile(videoFile)
 	if err != nil {
 		fmt.Println(err)
-		cancel(errors.New("unable to process file"))
-		wg.Done()
+		vP.fail(cancel, &vidInfo, newJobError(StageOpen, vidInfo.Frame, err))
 		return
 	}
 	defer video.Close()
@@ -211,121 +500,200 @@ func (vP *VideoProcessor) RunRecognizer(ctx context.Context, cancel context.Canc
 	var gr = vP.grCounter.Add(1)
 	defer vP.grCounter.Add(-1)
 
-	var frame_counter int64 = 1
-	var total_frames = video.Get(gocv.VideoCaptureProperties(7))
+	var frame_counter = vidInfo.Frame
+	var total_frames = video.Get(gocv.VideoCaptureFrameCount)
+	//continue from checkpoint
+	if frame_counter > 0 {
+		video.Set(gocv.VideoCapturePosFrames, float64(frame_counter))
+	}
+
+	// Размеченная копия видео пишется с начала, поэтому такие видео после перезапуска обрабатываются заново.
+	var writer *gocv.VideoWriter
+	if vidInfo.Options.Annotate {
+		writer, vidInfo.Annotated, err = openAnnotatedWriter(video, vP.config.Storage.Annotated, videoFile, id)
+		if err != nil {
+			vP.fail(cancel, &vidInfo, newJobError(StageAnnotate, vidInfo.Frame, err))
+			return
+		}
+		defer writer.Close()
+	}
 
 	// Инициализация изображения для очередного кадра.
 	img := gocv.NewMat()
 	defer img.Close()
 
+	// Снимок галереи, с которым сравниваются лица, и пороги, по которым принимается решение.
+	gallery := vP.gallery.Snapshot()
+	rec := vP.recognition(vidInfo.Options)
+
+	// Кадры, которые анализируются. Между ними в размеченную копию пишутся рамки последнего проанализированного кадра.
+	vidInfo.FPS = video.Get(gocv.VideoCaptureFPS)
+	sampler := newSampler(vidInfo.Options.Sampling, vidInfo.FPS)
+	// Кадры, которые почти не отличаются от последнего проанализированного, получают его результаты без детекции.
+	gate := newGate(vidInfo.Options.Gating)
+	defer gate.Close()
+	// Анализируемые кадры копятся в пачку и детектируются одним вызовом детектора, результаты выдаются по порядку кадров.
+	pending := newBatch(vP.config.Recognition.BatchSize, time.Duration(vP.config.Recognition.BatchLatency), writer != nil)
+	defer pending.reset()
+	out := &frameOutput{vP: vP, id: id, fileName: fileName, gr: gr, total: total_frames, writer: writer}
+	// Прогресс сохраняется не на каждом кадре, смена статуса, пауза, отмена и остановка сервиса сохраняют его сразу.
+	saved := newCheckpoint(vP.config.Recognition, frame_counter)
+
 	fmt.Printf("start reading video from: %s\n", videoFile)
 	for {
 		var progress = float64(frame_counter) / total_frames * 100
+		//paused worker blocks here with no slot until it is resumed, then continues from the same frame
+		if j.paused() != nil {
+			if !vP.flush(ctx, cancel, &vidInfo, pending, gallery, rec, out) {
+				return
+			}
+			vP.releaseSlot()
+			holdsSlot = vP.takeSlot(ctx, j, &vidInfo)
+		}
 		select {
 		//if request was canceled, goroutine is shutting down
 		case <-ctx.Done():
-			vidInfo.Status = 3
 			vidInfo.Percentage = progress
-			vP.dataBuffer <- vidInfo
+			out.commit()
+			vP.interrupt(ctx, &vidInfo)
 			cancel(errors.New("goroutine was canceled due to context cancel"))
-			wg.Done()
 			return
 		default:
-			if vidInfo.Status == 1 {
-				vidInfo.Status = 1
-				vidInfo.Percentage = progress
-				vP.dataBuffer <- vidInfo
-				if ok := video.Read(&img); !ok {
-					fmt.Printf("cannot read video from file %s\n", videoFile)
-					vidInfo.Status = 4
-					vidInfo.Percentage = 100.0
-					vP.dataBuffer <- vidInfo
-					wg.Done()
+			if pending.ready() && !vP.flush(ctx, cancel, &vidInfo, pending, gallery, rec, out) {
+				return
+			}
+			if ctx.Err() != nil {
+				continue
+			}
+			vidInfo.Percentage = progress
+			// После перезапуска обработка продолжается с первого кадра, который ждёт детекции в пачке.
+			vidInfo.Frame = frame_counter
+			if !pending.empty() {
+				vidInfo.Frame = pending.first()
+			}
+			if saved.due(frame_counter) {
+				out.commit()
+				vP.save(vidInfo)
+			}
+			// Пропускаемые кадры только захватываются без декодирования, если их не нужно писать в размеченную копию.
+			if skip := sampler.skip(frame_counter); skip > 0 && writer == nil {
+				video.Grab(skip)
+				frame_counter += int64(skip)
+				continue
+			}
+			if ok := video.Read(&img); !ok {
+				if !vP.flush(ctx, cancel, &vidInfo, pending, gallery, rec, out) {
 					return
 				}
-				if img.Empty() {
+				if ctx.Err() != nil {
 					continue
 				}
-				// Выявляем лица в кадре.
-				detects, err := detector.Detect(img)
-				if err != nil {
-					log.Fatalf("detect faces: %v", err)
-				}
-				// detect faces
-				// Для каждого выявленного лица.
-				for _, detect := range detects {
-
-					// Получаем вектор выявленного лица.
-					descriptor, err := recognizer.Recognize(img, detect.Rectangle, padding, jittering)
-					if err != nil {
-						log.Fatalf("recognize face: %v", err)
-					}
-
-					// Ищем в массиве векторов известных лиц наиболее близкое (по евклиду) лицо.
-					person, distance := findPerson(persons, descriptor)
-
-					// Рисуем прямоугольник выявленного лица.
-					//gocv.Rectangle(&img, detect.Rectangle, blue, 1)
-
-					// Если расстояние между найденным известным лицом и выявленным лицом меньше
-					// какого-то порога, то пишем имя найденного известного лица над нарисованным
-					// прямоугольником.
-					if distance <= matchDistance {
-						log.Printf("goroutine: %d, processId: %d - %.2f%%: found %s on frame %d of %s\n", gr, id, progress, person.Name, frame_counter, fileName)
-						//gocv.PutText(&img, person.Name, image.Point{
-						//	X: detect.Rectangle.Min.X,
-						//	Y: detect.Rectangle.Min.Y,
-						//}, gocv.FontHersheyComplex, 1, blue, 1)
+				fmt.Printf("cannot read video from file %s\n", videoFile)
+				vidInfo.Percentage = 100.0
+				vP.setStatus(&vidInfo, Successful, "all frames are processed")
+				cancel(nil)
+				return
+			}
+			frameIndex := frame_counter
+			timestamp := video.Get(gocv.VideoCapturePosMsec)
+			frame_counter++
+			if img.Empty() {
+				continue
+			}
+			analyze := sampler.analyze(frameIndex)
+			changed := analyze && gate.changed(img)
+			// Кадры без детекции ждут в пачке, если перед ними есть кадры, которые ещё не детектированы.
+			switch {
+			// Сюда доходят пропускаемые кадры только тех видео, для которых пишется размеченная копия.
+			case !analyze && pending.empty():
+				out.skipped(&img, frameIndex)
+			case !analyze:
+				pending.add(frameIndex, timestamp, img, false, false)
+			case !changed && pending.empty():
+				vidInfo.GatedFrames++
+				out.gated(&img, frameIndex, timestamp)
+			case !changed:
+				vidInfo.GatedFrames++
+				pending.add(frameIndex, timestamp, img, false, true)
+			default:
+				// Пачка, которую начинает этот кадр, сравнивается со свежим снимком галереи.
+				if pending.empty() && vidInfo.Options.Gallery != GalleryPinned {
+					if latest := vP.gallery.Snapshot(); latest.Version != gallery.Version {
+						log.Printf("video %d switched to gallery version %d on frame %d", id, latest.Version, frameIndex)
+						gallery = latest
 					}
-					frame_counter++
 				}
+				pending.add(frameIndex, timestamp, img, true, false)
 			}
 		}
 	}
 }
 
-// Вычисление евклидового расстояния.
-func euclidianDistance(a, b face.Descriptor) float64 {
-	var sum float64
-	for i := range a {
-		sum += math.Pow(float64(a[i])-float64(b[i]), 2)
+// finishes job, whose context is done: video is canceled, unless service is shutting down,
+// in this case video keeps its status and progress, so it is restored on next start
+func (vP *VideoProcessor) interrupt(ctx context.Context, vidInfo *Video) {
+	if errors.Is(context.Cause(ctx), ErrShutdown) {
+		vP.save(*vidInfo)
+		return
 	}
-	return math.Sqrt(sum)
+	vP.setStatus(vidInfo, Canceled, context.Cause(ctx).Error())
 }
 
-// Функция поиска наиболее близкой, заданному дескриптору, персоны.
-func findPerson(persons []Person, descriptor face.Descriptor) (Person, float64) {
-	// Объявляем переменные, которые будут хранить результаты поиска
-	var minPerson Person
-	var minDistance = math.MaxFloat64
-
-	// Проходимся по каждой персоне.
-	for _, person := range persons {
-		// Проходимся по каждому дескриптору персоны.
-		for _, personDescriptor := range person.Descriptors {
-			// Вычисляем расстояние между дескриптором персоны и заданным дескриптором.
-			distance := euclidianDistance(personDescriptor, descriptor)
+// Функция распознавания лиц, выявленных детектором на кадре.
+// Ошибка распознавателя возвращается как JobError, а найденные на кадре лица отбрасываются.
+func recognizeFaces(m *models, img gocv.Mat, detects []face.Detection, gallery *GallerySnapshot, rec recognition, frameIndex int64, timestamp float64) ([]FrameDetection, error) {
+	// Получаем векторы всех выявленных лиц одним проходом сети.
+	rects := make([]image.Rectangle, len(detects))
+	for i, detect := range detects {
+		rects[i] = detect.Rectangle
+	}
+	descriptors, err := m.recognizeAll(img, rects)
+	if err != nil {
+		return nil, newJobError(StageRecognize, frameIndex, err)
+	}
 
-			// Если полученное расстояние меньше текущего минимального, то сохраняем персону и расстояние в
-			// переменные результатов.
-			if distance < minDistance {
-				minDistance = distance
-				minPerson = person
-			}
+	var frameDetections []FrameDetection
+	// Для каждого выявленного лица.
+	for i, detect := range detects {
+		descriptor := descriptors[i]
+
+		// Ищем среди векторов известных лиц наиболее близкие лица по метрике задачи. Для проверки отрыва от второй
+		// персоны нужны как минимум две.
+		detection := FrameDetection{
+			Frame:       frameIndex,
+			TimestampMs: timestamp,
+			Rectangle:   detect.Rectangle,
+			Confidence:  detect.Confidence,
+			Distance:    math.MaxFloat64}
+		matches := rec.search(gallery, descriptor, max(rec.candidates, 2))
+		if len(matches) > 0 {
+			detection.Person, detection.Distance = matches[0].Person, matches[0].Distance
+			detection.MatchConfidence = matches[0].Confidence
+		}
+		if len(matches) > 1 {
+			detection.RunnerUp, detection.RunnerUpDistance = matches[1].Person, matches[1].Distance
 		}
+		detection.Candidates = matches[:min(len(matches), rec.candidates)]
+		// Лицо считается известным, только если все проверки порогов пройдены.
+		detection.Label, detection.Rejection = rec.decide(matches, gallery.Thresholds)
+		detection.Matched = detection.Label != Unknown
+		frameDetections = append(frameDetections, detection)
 	}
-	return minPerson, minDistance
+	return frameDetections, nil
 }
 
-// Функция загрузки базы персон.
-func loadPersons(detector *face.Detector, recognizer *face.Recognizer, personsPath string) (persons []Person) {
+// Функция загрузки базы персон. Возвращает дескрипторы фотографий каждой персоны по имени персоны и имени файла.
+// Дескрипторы фотографий, которые уже есть в кэше, не пересчитываются. Неподходящие фотографии пропускаются и
+// попадают в список проблем.
+func loadPersons(m *models, cache *descriptorCache, personsPath string) (map[string]map[string]face.Descriptor, []EnrollmentProblem) {
+	persons := make(map[string]map[string]face.Descriptor)
 	// Читаем директорию, получаем массив его содержимого (информацию о файлах и папках).
 	personsDirs, err := os.ReadDir(personsPath)
-	//personsDirs, err := ioutil.ReadDir(personsPath)
 	if err != nil {
-		log.Fatalf("read persons directory: %v", err)
+		return persons, []EnrollmentProblem{newProblem("", "", fmt.Errorf("read persons directory: %w", err))}
 	}
 
+	var problems []EnrollmentProblem
 	// По каждому элементу из директории персон.
 	for _, personDir := range personsDirs {
 		// Пропускаем не директории.
@@ -333,65 +701,103 @@ func loadPersons(detector *face.Detector, recognizer *face.Recognizer, personsPa
 			continue
 		}
 
-		// Формируем персону.
-		person := Person{
-			// Имя персоны ― название папки.
-			Name: personDir.Name(),
+		// Имя персоны ― название папки.
+		photos, personProblems := loadPerson(m, cache, personsPath, personDir.Name())
+		persons[personDir.Name()] = photos
+		problems = append(problems, personProblems...)
+	}
+
+	return persons, problems
+}
+
+// Функция загрузки фотографий одной персоны.
+func loadPerson(m *models, cache *descriptorCache, personsPath, name string) (map[string]face.Descriptor, []EnrollmentProblem) {
+	photos := make(map[string]face.Descriptor)
+	// Читаем директорию персоны.
+	personsFiles, err := os.ReadDir(path.Join(personsPath, name))
+	if err != nil {
+		return photos, []EnrollmentProblem{newProblem(name, "", fmt.Errorf("read person directory: %w", err))}
+	}
+
+	var problems []EnrollmentProblem
+	// По каждому элементу из директории персоны.
+	for _, personFile := range personsFiles {
+		// Пропускаем если директория или файл с порогами персоны.
+		if personFile.IsDir() || personFile.Name() == thresholdsFile {
+			continue
 		}
 
-		// Читаем директорию персоны.
-		personsFiles, err := os.ReadDir(path.Join(personsPath, personDir.Name()))
+		// Читаем файл.
+		data, err := os.ReadFile(path.Join(personsPath, name, personFile.Name()))
 		if err != nil {
-			log.Fatalf("read person directory: %v", err)
+			problems = append(problems, newProblem(name, personFile.Name(), err))
+			continue
 		}
 
-		// По каждому элементу из директории персоны.
-		for _, personFile := range personsFiles {
-			// Пропускаем если директория.
-			if personFile.IsDir() {
-				continue
-			}
-
-			filePath := path.Join(personsPath, personDir.Name(), personFile.Name())
-
-			// Читаем и декодируем изображение.
-			img := gocv.IMRead(filePath, gocv.IMReadUnchanged)
+		// Если не удалось получить дескриптор, то запоминаем проблему и идём к следующему файлу.
+		descriptor, err := enrollPhoto(m, cache, data)
+		if err != nil {
+			problems = append(problems, newProblem(name, personFile.Name(), err))
+			continue
+		}
 
-			// Если не удалось прочитать файл и декодировать изображение, то пропускаем файл.
-			if img.Empty() {
-				continue
-			}
+		// Добавляем вектор в массив векторов персоны.
+		photos[personFile.Name()] = descriptor
+	}
+	return photos, problems
+}
 
-			// Выявляем лица на изображении.
-			detects, err := detector.Detect(img)
-			if err != nil {
-				log.Fatalf("detect on person image: %v", err)
-			}
+// Функция получения дескриптора лица на фотографии персоны. Если на фотографии несколько лиц, то берётся самое
+// крупное и уверенное из них.
+func enrollPhoto(m *models, cache *descriptorCache, data []byte) (face.Descriptor, error) {
+	// Если дескриптор этой фотографии уже посчитан теми же моделями, то берём его из кэша.
+	key := cache.key(data)
+	if descriptor, ok := cache.get(key); ok {
+		return descriptor, nil
+	}
 
-			// Если кол-во лиц не 1, то завершаем программу с ошибкой.
-			if len(detects) != 1 {
-				log.Fatalf("multple faces detected on photo %s", filePath)
-			}
+	// Декодируем изображение и выявляем на нём лица.
+	img, detects, err := detectPhoto(m, data)
+	if err != nil {
+		return face.Descriptor{}, err
+	}
+	// Освобождаем память, выделенную под изображение.
+	defer img.Close()
 
-			// Получаем вектор лица на изображении.
-			descriptor, err := recognizer.Recognize(img, detects[0].Rectangle, padding, jittering)
-			if err != nil {
-				log.Fatalf("recognize persons face: %v", err)
-			}
+	// Выбираем лицо, если лиц нет, то фотография не подходит.
+	selected, err := selectFace(detects, FaceSelector{})
+	if err != nil {
+		return face.Descriptor{}, err
+	}
 
-			// Добавляем вектор в массив векторов персоны.
-			person.Descriptors = append(person.Descriptors, descriptor)
+	// Получаем вектор лица на изображении.
+	descriptor, err := m.recognize(img, detects[selected].Rectangle)
+	if err != nil {
+		return face.Descriptor{}, fmt.Errorf("%w: %w", ErrRecognizerFailed, err)
+	}
 
-			// Освобождаем память, выделенную под изображение.
-			err = img.Close()
-			if err != nil {
-				log.Fatalf("close image: %v", err)
-			}
-		}
+	// Добавляем вектор в кэш.
+	cache.put(key, descriptor)
+	return descriptor, nil
+}
 
-		// Добавляем очередную персону в массив персон.
-		persons = append(persons, person)
+// Функция декодирования фотографии и выявления лиц на ней. Изображение нужно освободить после использования.
+func detectPhoto(m *models, data []byte) (gocv.Mat, []face.Detection, error) {
+	// Декодируем изображение.
+	img, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
+	if err != nil {
+		return img, nil, fmt.Errorf("%w: %w", ErrUnreadableImage, err)
+	}
+	if img.Empty() {
+		img.Close()
+		return img, nil, ErrUnreadableImage
 	}
 
-	return persons
+	// Выявляем лица на изображении.
+	detects, err := m.detector.Detect(img)
+	if err != nil {
+		img.Close()
+		return img, nil, fmt.Errorf("%w: %w", ErrDetectorFailed, err)
+	}
+	return img, detects, nil
 }
@@ -83,3 +83,49 @@ func (r *Recognizer) Recognize(img gocv.Mat, faceLocation image.Rectangle, paddi
 
 	return d, nil
 }
+
+// RecognizeBatch performs face vectorization process on every face location
+// from faceLocations on given image img in one pass of the network. Each
+// returned descriptor corresponds to face location with the same index.
+func (r *Recognizer) RecognizeBatch(img gocv.Mat, faceLocations []image.Rectangle, padding float64, jittering int) ([]Descriptor, error) {
+	if len(faceLocations) == 0 {
+		return nil, nil
+	}
+
+	cFaceLocations := make([]C.rectangle, len(faceLocations))
+	for i, faceLocation := range faceLocations {
+		cFaceLocations[i].min.x = C.int(faceLocation.Min.X)
+		cFaceLocations[i].min.y = C.int(faceLocation.Min.Y)
+		cFaceLocations[i].max.x = C.int(faceLocation.Max.X)
+		cFaceLocations[i].max.y = C.int(faceLocation.Max.Y)
+	}
+
+	result := C.recognizer_batch_recognize(r.recognizer, unsafe.Pointer(img.Ptr()), &cFaceLocations[0], C.int(len(cFaceLocations)), C.double(padding), C.int(jittering))
+	defer C.free(unsafe.Pointer(result))
+
+	if result.error_message != nil {
+		defer C.free(unsafe.Pointer(result.error_message))
+		return nil, errors.New(C.GoString(result.error_message))
+	}
+
+	defer C.free(unsafe.Pointer(result.descriptors))
+
+	if int(result.descriptors_count) != len(faceLocations) {
+		return nil, errors.New("recognizer returned wrong number of descriptors")
+	}
+
+	var descriptors []C.float
+	descriptorsHeader := (*reflect.SliceHeader)(unsafe.Pointer(&descriptors))
+	descriptorsHeader.Cap = int(result.descriptors_count) * DescriptorSize
+	descriptorsHeader.Len = int(result.descriptors_count) * DescriptorSize
+	descriptorsHeader.Data = uintptr(unsafe.Pointer(result.descriptors))
+
+	ds := make([]Descriptor, result.descriptors_count)
+	for i := range ds {
+		for j := range ds[i] {
+			ds[i][j] = float32(descriptors[i*DescriptorSize+j])
+		}
+	}
+
+	return ds, nil
+}
@@ -12,6 +12,12 @@ typedef struct {
     char* error_message;
 } recognizer_recognize_result;
 
+typedef struct {
+    float* descriptors;
+    int descriptors_count;
+    char* error_message;
+} recognizer_batch_recognize_result;
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -20,6 +26,7 @@ recognizer_init_result* recognizer_init(char* shaper_model_file_path, char* reco
 void recognizer_free(void* recognizer);
 
 recognizer_recognize_result* recognizer_recognize(void* recognizer, void* image, rectangle* face_location, double padding, int jittering);
+recognizer_batch_recognize_result* recognizer_batch_recognize(void* recognizer, void* image, rectangle* face_locations, int face_locations_count, double padding, int jittering);
 
 #ifdef __cplusplus
 }
@@ -55,27 +55,50 @@ public:
     }
 
     dlib::matrix<float,0,1> recognize(const dlib::matrix<dlib::rgb_pixel>& image, dlib::rectangle face_location, double padding, int jittering) {
-        auto shape = shaper(image, face_location);
+        return recognize(image, std::vector<dlib::rectangle>{face_location}, padding, jittering)[0];
+    }
+
+    std::vector<dlib::matrix<float,0,1>> recognize(const dlib::matrix<dlib::rgb_pixel>& image, const std::vector<dlib::rectangle>& face_locations, double padding, int jittering) {
+        int chips_per_face = jittering > 0 ? jittering : 1;
+
+        std::vector<dlib::matrix<dlib::rgb_pixel>> chips;
+        chips.reserve(face_locations.size() * chips_per_face);
 
-        dlib::matrix<dlib::rgb_pixel> chip;
-        dlib::extract_image_chip(image, dlib::get_face_chip_details(shape, IMAGE_SIZE, padding), chip);
+        for (auto& face_location : face_locations) {
+            auto shape = shaper(image, face_location);
 
-        dlib::matrix<float,0,1> descriptor;
+            dlib::matrix<dlib::rgb_pixel> chip;
+            dlib::extract_image_chip(image, dlib::get_face_chip_details(shape, IMAGE_SIZE, padding), chip);
 
-        if (jittering > 0) {
-            auto chips = jitter_image(chip, jittering);
-            std::vector<dlib::matrix<float,0,1>> descriptors;
-            {
-                std::lock_guard lock(net_mutex);
-                descriptors = net(chips);
+            if (jittering > 0) {
+                auto crops = jitter_image(chip, jittering);
+                chips.insert(chips.end(), crops.begin(), crops.end());
+            } else {
+                chips.push_back(std::move(chip));
             }
-            descriptor = dlib::mean(dlib::mat(descriptors));
-        } else {
+        }
+
+        std::vector<dlib::matrix<float,0,1>> chip_descriptors;
+        {
             std::lock_guard lock(net_mutex);
-            descriptor = net(chip);
+            chip_descriptors = net(chips);
+        }
+
+        if (jittering == 0) {
+            return chip_descriptors;
+        }
+
+        std::vector<dlib::matrix<float,0,1>> descriptors;
+        descriptors.reserve(face_locations.size());
+
+        for (unsigned long i = 0; i < face_locations.size(); i++) {
+            std::vector<dlib::matrix<float,0,1>> face_descriptors(
+                chip_descriptors.begin() + i * chips_per_face,
+                chip_descriptors.begin() + (i + 1) * chips_per_face);
+            descriptors.push_back(dlib::mean(dlib::mat(face_descriptors)));
         }
 
-        return descriptor;
+        return descriptors;
     }
 
 private:
@@ -144,4 +167,51 @@ recognizer_recognize_result* recognizer_recognize(void* recognizer, void* image,
     }
 
     return result;
-}
\ No newline at end of file
+}
+
+recognizer_batch_recognize_result* recognizer_batch_recognize(void* recognizer, void* image, rectangle* face_locations, int face_locations_count, double padding, int jittering) {
+    recognizer_batch_recognize_result* result = (recognizer_batch_recognize_result*)malloc(sizeof(recognizer_batch_recognize_result));
+
+    try {
+        dlib::matrix<dlib::rgb_pixel> dlib_image;
+
+        cv::Mat* opencv_image = (cv::Mat*)image;
+
+        if (opencv_image->channels() > 1) {
+            dlib::assign_image(dlib_image, dlib::cv_image<dlib::bgr_pixel>(*opencv_image));
+        } else {
+            dlib::assign_image(dlib_image, dlib::cv_image<uchar>(*opencv_image));
+        }
+
+        std::vector<dlib::rectangle> dlib_face_locations(face_locations_count);
+
+        for (int i = 0; i < face_locations_count; i++) {
+            dlib_face_locations[i].set_left(face_locations[i].min.x);
+            dlib_face_locations[i].set_top(face_locations[i].min.y);
+            dlib_face_locations[i].set_right(face_locations[i].max.x);
+            dlib_face_locations[i].set_bottom(face_locations[i].max.y);
+        }
+
+        auto dlib_descriptors = ((Recognizer*)(recognizer))->recognize(dlib_image, dlib_face_locations, padding, jittering);
+
+        long descriptor_size = dlib_descriptors.empty() ? 0 : dlib_descriptors[0].nr();
+        float* descriptors = (float*)calloc(dlib_descriptors.size() * descriptor_size, sizeof(float));
+
+        for (unsigned long i = 0; i < dlib_descriptors.size(); i++) {
+            for (long j = 0; j < descriptor_size; j++) {
+                descriptors[i * descriptor_size + j] = dlib_descriptors[i](j, 0);
+            }
+        }
+
+        result->descriptors = descriptors;
+        result->descriptors_count = dlib_descriptors.size();
+        result->error_message = NULL;
+
+    } catch (std::exception& e) {
+        result->descriptors = NULL;
+        result->descriptors_count = 0;
+        result->error_message = strdup(e.what());
+    }
+
+    return result;
+}